@@ -0,0 +1,352 @@
+package config
+
+import (
+	"encoding"
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+	sizeType     = reflect.TypeOf(ByteSize(0))
+)
+
+// binding ties an Option created by BindStruct to the struct field it fills.
+type binding struct {
+	path   string
+	option *Option
+	field  reflect.Value
+}
+
+// BindStruct creates an Option for each exported field of the struct pointed to by `v`, and fills those fields with the
+// Options' values every time the Config is built. Fields are configured with struct tags:
+//
+//	config:"addend.a"           the Option's name; defaults to the lower-cased field name, and "-" skips the field
+//	default:"10"                the Option's default value; defaults to the field's current value
+//	desc:"The first addend"     the Option's description
+//	export:"true"               whether the Option is exportable to a config file
+//
+// Fields that are structs themselves are walked too, and their Options are named with the field's name as a prefix,
+// i.e. field A in a struct field named addend becomes addend.a.
+//
+// The fields are only filled by a build that succeeds. Watch and Set don't change them, since the struct can't be read
+// safely while it's being changed; read the Options, or use OnChange, to see values from those.
+func (c *Config) BindStruct(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("go-config: BindStruct needs a pointer to a struct, got %T", v)
+	}
+
+	path := rv.Elem().Type().Name()
+	if path == "" {
+		path = "struct"
+	}
+
+	bindings, err := bindStruct(rv.Elem(), path, "")
+	if err != nil {
+		return err
+	}
+
+	for _, b := range bindings {
+		c.Add(b.option)
+	}
+	c.bindings = append(c.bindings, bindings...)
+
+	return nil
+}
+
+// BindStruct binds the struct pointed to by `v` to the default Config. See (*Config).BindStruct.
+func BindStruct(v interface{}) error {
+	return defaultConfig().BindStruct(v)
+}
+
+func bindStruct(rv reflect.Value, path string, prefix string) ([]binding, error) {
+	bindings := []binding{}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if sf.PkgPath != "" {
+			// unexported
+			continue
+		}
+
+		name := sf.Tag.Get("config")
+		if name == "-" {
+			continue
+		} else if name == "" {
+			name = strings.ToLower(sf.Name)
+		}
+
+		fieldPath := path + "." + sf.Name
+		field := rv.Field(i)
+
+		def, hasDefault := sf.Tag.Lookup("default")
+
+		var opt *Option
+		var err error
+		if field.Type() == timeType {
+			if !hasDefault {
+				def = formatValue(field.Interface())
+			}
+
+			t, perr := parseTime(def)
+			if perr != nil {
+				return nil, fmt.Errorf("go-config: %s: invalid default %q: expected %s", fieldPath, def, TimeType)
+			}
+			opt = Time(prefix+name, t, sf.Tag.Get("desc"))
+		} else if isVarField(field) {
+			// types that know how to parse themselves are set in place
+			opt = Var(prefix+name, field.Addr().Interface(), sf.Tag.Get("desc"))
+			if hasDefault {
+				err = setCustomText(opt.Value(), def)
+				if err != nil {
+					return nil, fmt.Errorf("go-config: %s: invalid default %q: %s", fieldPath, def, err)
+				}
+				opt.DefaultValue = def
+			}
+		} else if field.Kind() == reflect.Struct {
+			children, err := bindStruct(field, fieldPath, prefix+name+".")
+			if err != nil {
+				return nil, err
+			}
+
+			bindings = append(bindings, children...)
+			continue
+		} else {
+			if !hasDefault {
+				def = formatValue(field.Interface())
+			}
+
+			opt, err = bindOption(prefix+name, field, def, sf.Tag.Get("desc"))
+			if err != nil {
+				return nil, fmt.Errorf("go-config: %s: %s", fieldPath, err)
+			}
+		}
+
+		if export, ok := sf.Tag.Lookup("export"); ok {
+			v, err := strconv.ParseBool(export)
+			if err != nil {
+				return nil, fmt.Errorf("go-config: %s: invalid export tag %q: %s", fieldPath, export, err)
+			}
+			opt.Exportable(v)
+		}
+
+		bindings = append(bindings, binding{
+			path:   fieldPath,
+			option: opt,
+			field:  field,
+		})
+	}
+
+	return bindings, nil
+}
+
+// isVarField returns true if a pointer to `field` can be used with Var.
+func isVarField(field reflect.Value) bool {
+	if !field.CanAddr() {
+		return false
+	}
+
+	switch field.Addr().Interface().(type) {
+	case flag.Value, encoding.TextUnmarshaler:
+		return true
+	}
+	return false
+}
+
+// bindOption creates an Option for `field`, parsing `def` as its default value.
+func bindOption(name string, field reflect.Value, def string, desc string) (*Option, error) {
+	switch field.Type() {
+	case durationType:
+		v, err := time.ParseDuration(def)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default %q: expected %s", def, DurationType)
+		}
+		return Duration(name, v, desc), nil
+
+	case sizeType:
+		v, err := parseSize(def)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default %q: expected %s", def, SizeType)
+		}
+		return Size(name, v, desc), nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		return Str(name, def, desc), nil
+
+	case reflect.Bool:
+		v, err := strconv.ParseBool(def)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default %q: expected %s", def, BoolType)
+		}
+		return Bool(name, v, desc), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseInt(def, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default %q: expected %s", def, IntType)
+		}
+		return Int(name, v, desc), nil
+
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(def, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default %q: expected %s", def, FloatType)
+		}
+		return Float(name, v, desc), nil
+
+	case reflect.Map:
+		if field.Type().Key().Kind() != reflect.String || field.Type().Elem().Kind() != reflect.String {
+			break
+		}
+
+		v, err := parseStrMap(def)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default %q: %s", def, err)
+		}
+		return StrMap(name, v, desc), nil
+
+	case reflect.Slice:
+		t := sliceTypeOf(field.Type().Elem().Kind())
+		if t == "" {
+			break
+		}
+
+		v, err := parseSlice(t, splitList(def))
+		if err != nil {
+			return nil, fmt.Errorf("invalid default %q: %s", def, err)
+		}
+
+		switch t {
+		case StrSliceType:
+			return StrSlice(name, v.([]string), desc), nil
+		case IntSliceType:
+			return IntSlice(name, v.([]int64), desc), nil
+		case FloatSliceType:
+			return FloatSlice(name, v.([]float64), desc), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported field type %s", field.Type())
+}
+
+// sliceTypeOf returns the slice Type that holds elements of kind `k`, or "" if there isn't one.
+func sliceTypeOf(k reflect.Kind) Type {
+	switch k {
+	case reflect.String:
+		return StrSliceType
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return IntSliceType
+	case reflect.Float32, reflect.Float64:
+		return FloatSliceType
+	}
+	return ""
+}
+
+// fill sets the bound struct field to the Option's value.
+func (b binding) fill() error {
+	if b.option.Type == CustomType {
+		// the field is the Option's variable, which is filled by fillVars
+		return nil
+	}
+
+	switch b.field.Type() {
+	case durationType:
+		b.field.SetInt(int64(b.option.Duration()))
+		return nil
+	case timeType:
+		b.field.Set(reflect.ValueOf(b.option.Time()))
+		return nil
+	case sizeType:
+		b.field.SetUint(b.option.Bytes())
+		return nil
+	}
+
+	switch b.field.Kind() {
+	case reflect.String:
+		b.field.SetString(b.option.Str())
+
+	case reflect.Bool:
+		b.field.SetBool(b.option.Bool())
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v := b.option.Int()
+		if b.field.OverflowInt(v) {
+			return BindError{Field: b.path, Option: b.option.Name, Value: v, Type: b.field.Type()}
+		}
+		b.field.SetInt(v)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v := b.option.Int()
+		if v < 0 || b.field.OverflowUint(uint64(v)) {
+			return BindError{Field: b.path, Option: b.option.Name, Value: v, Type: b.field.Type()}
+		}
+		b.field.SetUint(uint64(v))
+
+	case reflect.Float32, reflect.Float64:
+		v := b.option.Float()
+		if b.field.OverflowFloat(v) {
+			return BindError{Field: b.path, Option: b.option.Name, Value: v, Type: b.field.Type()}
+		}
+		b.field.SetFloat(v)
+
+	case reflect.Map:
+		dst := reflect.MakeMap(b.field.Type())
+		for k, v := range b.option.StrMap() {
+			dst.SetMapIndex(reflect.ValueOf(k).Convert(b.field.Type().Key()), reflect.ValueOf(v).Convert(b.field.Type().Elem()))
+		}
+		b.field.Set(dst)
+
+	case reflect.Slice:
+		src := reflect.ValueOf(b.option.Value())
+		dst := reflect.MakeSlice(b.field.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			el := dst.Index(i)
+			switch el.Kind() {
+			case reflect.String:
+				el.SetString(src.Index(i).String())
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				v := src.Index(i).Int()
+				if el.OverflowInt(v) {
+					return BindError{Field: fmt.Sprintf("%s[%d]", b.path, i), Option: b.option.Name, Value: v, Type: el.Type()}
+				}
+				el.SetInt(v)
+			case reflect.Float32, reflect.Float64:
+				v := src.Index(i).Float()
+				if el.OverflowFloat(v) {
+					return BindError{Field: fmt.Sprintf("%s[%d]", b.path, i), Option: b.option.Name, Value: v, Type: el.Type()}
+				}
+				el.SetFloat(v)
+			}
+		}
+		b.field.Set(dst)
+	}
+
+	return nil
+}
+
+// fillBindings fills every struct field bound with BindStruct.
+func (c *Config) fillBindings() error {
+	errs := []error{}
+	for _, b := range c.bindings {
+		err := b.fill()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return MultiError{Errors: errs, heading: "go-config: error(s) filling bound struct"}
+	}
+
+	return nil
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Change describes a change to an Option's value.
+type Change struct {
+	// Name is the name of the Option that changed.
+	Name string
+
+	// Old and New are the Option's values before and after the change. Custom (Var) values are pointers, so they're
+	// given as text instead.
+	Old interface{}
+	New interface{}
+
+	// Scope is where the new value came from, e.g. "app", "env", "flag" or "runtime", or "default" if the Option
+	// went back to its default value.
+	Scope string
+}
+
+// listeners holds the functions to call when an Option changes. The copies of an Option made while it's being built
+// share it with the original, so functions can be added while the Option is being copied.
+type listeners struct {
+	sync.RWMutex
+	fns []*listener
+}
+
+// listener is a function that's called when an Option changes.
+type listener struct {
+	fn func(Change)
+}
+
+// listen adds `fn` to the functions called when the Option changes, and returns it so that it can be removed.
+func (o *Option) listen(fn func(Change)) *listener {
+	if o.listeners == nil {
+		o.listeners = &listeners{}
+	}
+
+	l := &listener{fn: fn}
+
+	o.listeners.Lock()
+	defer o.listeners.Unlock()
+
+	o.listeners.fns = append(o.listeners.fns, l)
+	return l
+}
+
+// unlisten removes `l` from the functions called when the Option changes.
+func (o *Option) unlisten(l *listener) {
+	o.listeners.Lock()
+	defer o.listeners.Unlock()
+
+	fns := make([]*listener, 0, len(o.listeners.fns))
+	for _, v := range o.listeners.fns {
+		if v != l {
+			fns = append(fns, v)
+		}
+	}
+	o.listeners.fns = fns
+}
+
+// OnChange calls `fn` with the old and new values whenever the Option's value changes after a build, reload or call to
+// Set, once all of the Options are valid. A build that fails keeps the values it loaded, like it always has, but
+// doesn't report them, so `fn` is never called with a value that failed validation; the next change it's called with
+// starts from the last value it saw. Changes are passed to the listeners one at a time, in the order they were made,
+// once the Config is free to be built or set again; `fn` usually runs on the goroutine that made the change.
+func (o *Option) OnChange(fn func(old, new interface{})) *Option {
+	o.listen(func(c Change) {
+		fn(c.Old, c.New)
+	})
+	return o
+}
+
+// subscription holds the Changes waiting to be sent on a channel returned by Subscribe, so that a channel that isn't
+// being read doesn't hold up the Config.
+type subscription struct {
+	mu      sync.Mutex
+	pending []Change
+	ready   chan struct{}
+}
+
+func (s *subscription) add(c Change) {
+	s.mu.Lock()
+	s.pending = append(s.pending, c)
+	s.mu.Unlock()
+
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// send sends the waiting Changes on `ch`, in order, until `ctx` is done.
+func (s *subscription) send(ctx context.Context, ch chan<- Change) {
+	for {
+		s.mu.Lock()
+		if len(s.pending) == 0 {
+			s.mu.Unlock()
+
+			select {
+			case <-s.ready:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		next := s.pending[0]
+		s.mu.Unlock()
+
+		select {
+		case ch <- next:
+			s.mu.Lock()
+			s.pending = s.pending[1:]
+			s.mu.Unlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Subscribe returns a channel that receives a Change whenever the value of an Option in the OptionSet whose name
+// starts with `prefix` changes, until `ctx` is done. The channel is closed then, and the Options stop keeping track of
+// it. Changes are sent in the same order OnChange sees them; ones that haven't been read yet are held until they are,
+// so a slow reader doesn't hold up building, reloading or setting the Options. Options added to the OptionSet after
+// Subscribe is called aren't included.
+func (os OptionSet) Subscribe(ctx context.Context, prefix string) <-chan Change {
+	ch := make(chan Change)
+	s := &subscription{ready: make(chan struct{}, 1)}
+
+	subscribed := map[*Option]*listener{}
+	for _, v := range os {
+		if !v.isBuiltIn && strings.HasPrefix(v.Name, prefix) {
+			subscribed[v] = v.listen(s.add)
+		}
+	}
+
+	go func() {
+		defer close(ch)
+		defer func() {
+			for o, l := range subscribed {
+				o.unlisten(l)
+			}
+		}()
+
+		s.send(ctx, ch)
+	}()
+
+	return ch
+}
+
+// Subscribe returns a channel of changes to the default Config's Options whose names start with `prefix`, until `ctx`
+// is done. See OptionSet.Subscribe.
+func Subscribe(ctx context.Context, prefix string) <-chan Change {
+	return baseConfig.Options().Subscribe(ctx, prefix)
+}
+
+// changeOf returns the Change from `prev` to `next` for an Option, and false if its value didn't change.
+func (o *Option) changeOf(prev, next *optionState) (Change, bool) {
+	c := Change{
+		Name:  o.Name,
+		Old:   prev.value,
+		New:   next.value,
+		Scope: "default",
+	}
+
+	if len(next.provenance) > 0 {
+		c.Scope = next.provenance[len(next.provenance)-1].Scope
+	}
+
+	if o.Type == CustomType {
+		c.Old, c.New = customText(prev.value), customText(next.value)
+	}
+
+	return c, !reflect.DeepEqual(c.Old, c.New)
+}
+
+// queuedChange is a Change waiting to be passed to the listeners of the Option that changed.
+type queuedChange struct {
+	listeners *listeners
+	change    Change
+}
+
+// queue adds the Changes made to the OptionSet's Options to the ones waiting to be passed to their listeners, in order
+// of the Options' names.
+func (c *Config) queue(changes []Change) {
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+
+	c.notifyMu.Lock()
+	defer c.notifyMu.Unlock()
+
+	for _, ch := range changes {
+		if o, ok := c.options[ch.Name]; ok && o.listeners != nil {
+			c.pending = append(c.pending, queuedChange{listeners: o.listeners, change: ch})
+		}
+	}
+}
+
+// notify passes the waiting Changes to their listeners, in order. It's called once buildMu is released, so that slow
+// listeners don't hold up building, reloading or setting the Options. If another goroutine is already passing Changes
+// on, it passes these on too, so that the listeners never see them out of order.
+func (c *Config) notify() {
+	c.notifyMu.Lock()
+	if c.notifying {
+		c.notifyMu.Unlock()
+		return
+	}
+	c.notifying = true
+
+	for len(c.pending) > 0 {
+		pending := c.pending
+		c.pending = nil
+		c.notifyMu.Unlock()
+
+		for _, q := range pending {
+			q.listeners.RLock()
+			fns := q.listeners.fns
+			q.listeners.RUnlock()
+
+			for _, l := range fns {
+				l.fn(q.change)
+			}
+		}
+
+		c.notifyMu.Lock()
+	}
+
+	c.notifying = false
+	c.notifyMu.Unlock()
+}
@@ -0,0 +1,331 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// A Codec reads and writes one config file format.
+type Codec interface {
+	// Decode decodes the contents of a config file into nested maps keyed by each part of the Options' names, i.e.
+	// addend.a is found at ["addend"]["a"]. Integers should be decoded as int64 and floating point numbers as float64;
+	// untyped values should be decoded as RawValues. Lists should be []interface{} and tables map[string]interface{}.
+	// Values of any other type are reported as TypeErrors.
+	Decode([]byte) (map[string]interface{}, error)
+
+	// Encode encodes an exported OptionSet, in the same form Decode returns.
+	Encode(map[string]interface{}) ([]byte, error)
+
+	// Extensions returns the file extensions, including the leading dot, of files in this format.
+	Extensions() []string
+}
+
+// RawValue is an untyped value decoded from a format that doesn't have types, like INI. It's parsed with the Option's
+// SetFromString, and Line and Column are used to point at the value in error messages and its Provenance.
+type RawValue struct {
+	Text   string
+	Line   int
+	Column int
+}
+
+var (
+	codecs     = map[string]Codec{}
+	codecsLock sync.RWMutex
+)
+
+func init() {
+	RegisterCodec(jsonCodec{})
+	RegisterCodec(yamlCodec{})
+	RegisterCodec(tomlCodec{})
+	RegisterCodec(iniCodec{})
+	RegisterCodec(dotenvCodec{})
+}
+
+// RegisterCodec makes a Codec available for config files with any of its extensions, replacing any Codec that was
+// registered for those extensions before.
+func RegisterCodec(c Codec) {
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+
+	for _, ext := range c.Extensions() {
+		codecs[strings.ToLower(ext)] = c
+	}
+}
+
+// codecFor returns the Codec for a file. If `format` is set, it's the extension of the Codec to use (with or without
+// the leading dot); otherwise the Codec is picked by the file's extension, and files with an unknown extension are JSON.
+func codecFor(filename string, format string) (Codec, error) {
+	codecsLock.RLock()
+	defer codecsLock.RUnlock()
+
+	if format != "" {
+		c, ok := codecs["."+strings.ToLower(strings.TrimPrefix(format, "."))]
+		if !ok {
+			return nil, fmt.Errorf("no codec registered for format %q", format)
+		}
+		return c, nil
+	}
+
+	if c, ok := codecs[strings.ToLower(filepath.Ext(filename))]; ok {
+		return c, nil
+	}
+
+	return jsonCodec{}, nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Decode(in []byte) (map[string]interface{}, error) {
+	jmap := jsonConfigMap{}
+	err := json.Unmarshal(in, &jmap)
+	return jmap.config, err
+}
+
+func (jsonCodec) Encode(in map[string]interface{}) ([]byte, error) {
+	return json.MarshalIndent(in, "", "\t")
+}
+
+func (jsonCodec) Extensions() []string {
+	return []string{".json"}
+}
+
+func (jsonCodec) Locate(in []byte) (map[string]Position, error) {
+	out := map[string]Position{}
+
+	// numbers are only skipped over, so keep them as json.Numbers, like Decode does, so that ones too large for a
+	// float64 are reported when they're parsed instead of failing here
+	dec := json.NewDecoder(bytes.NewReader(in))
+	dec.UseNumber()
+
+	err := locateJSON(dec, in, "", true, out)
+	return out, err
+}
+
+// locateJSON reads the next value from `dec`, recording where it starts as `key` if `track` is set, along with the
+// values of any object members inside it. Values inside arrays aren't recorded.
+func locateJSON(dec *json.Decoder, in []byte, key string, track bool, out map[string]Position) error {
+	// the decoder's offset is the end of the last token, so skip past the separators to the start of this value
+	start := int(dec.InputOffset())
+	for start < len(in) && strings.IndexByte(" \t\r\n:,", in[start]) >= 0 {
+		start++
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if track && key != "" {
+		out[key] = positionOf(in, start)
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return err
+			}
+
+			name := k.(string)
+			if key != "" {
+				name = key + "." + name
+			}
+
+			err = locateJSON(dec, in, name, track, out)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+
+	case json.Delim('['):
+		for dec.More() {
+			err = locateJSON(dec, in, "", false, out)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+	}
+
+	return err
+}
+
+// positionOf returns the line and column of the byte at `offset` in `in`.
+func positionOf(in []byte, offset int) Position {
+	before := in[:offset]
+	return Position{
+		Line:   bytes.Count(before, []byte("\n")) + 1,
+		Column: offset - bytes.LastIndexByte(before, '\n'),
+	}
+}
+
+type yamlCodec struct{}
+
+func (yamlCodec) Decode(in []byte) (map[string]interface{}, error) {
+	out := map[string]interface{}{}
+	err := yaml.Unmarshal(in, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return normalizeYAML(out).(map[string]interface{}), nil
+}
+
+func (yamlCodec) Encode(in map[string]interface{}) ([]byte, error) {
+	return yaml.Marshal(in)
+}
+
+func (yamlCodec) Extensions() []string {
+	return []string{".yaml", ".yml"}
+}
+
+func (yamlCodec) Locate(in []byte) (map[string]Position, error) {
+	doc := yaml.Node{}
+	err := yaml.Unmarshal(in, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[string]Position{}
+	if len(doc.Content) > 0 {
+		locateYAML(doc.Content[0], "", out)
+	}
+	return out, nil
+}
+
+// locateYAML records where the value of each key in the mapping `n` starts, and the keys in any mappings inside it.
+func locateYAML(n *yaml.Node, prefix string, out map[string]Position) {
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key := prefix + n.Content[i].Value
+		v := n.Content[i+1]
+
+		out[key] = Position{Line: v.Line, Column: v.Column}
+		locateYAML(v, key+".", out)
+	}
+}
+
+type tomlCodec struct{}
+
+func (tomlCodec) Decode(in []byte) (map[string]interface{}, error) {
+	out := map[string]interface{}{}
+	err := toml.Unmarshal(in, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return normalizeTOML(out).(map[string]interface{}), nil
+}
+
+func (tomlCodec) Encode(in map[string]interface{}) ([]byte, error) {
+	buf := bytes.Buffer{}
+	err := toml.NewEncoder(&buf).Encode(in)
+	return buf.Bytes(), err
+}
+
+func (tomlCodec) Extensions() []string {
+	return []string{".toml"}
+}
+
+// normalizeTOML converts arrays of tables decoded from a TOML document into plain arrays, like every other array.
+func normalizeTOML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, el := range v {
+			v[k] = normalizeTOML(el)
+		}
+		return v
+
+	case []map[string]interface{}:
+		out := make([]interface{}, len(v))
+		for i, el := range v {
+			out[i] = normalizeTOML(el)
+		}
+		return out
+
+	case []interface{}:
+		for i, el := range v {
+			v[i] = normalizeTOML(el)
+		}
+		return v
+	}
+
+	return v
+}
+
+// normalizeYAML converts the values decoded from a YAML document into the types parse() expects: mappings with
+// string keys, and integers as int64.
+func normalizeYAML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, el := range v {
+			v[k] = normalizeYAML(el)
+		}
+		return v
+
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, el := range v {
+			out[fmt.Sprintf("%v", k)] = normalizeYAML(el)
+		}
+		return out
+
+	case []interface{}:
+		for i, el := range v {
+			v[i] = normalizeYAML(el)
+		}
+		return v
+
+	case int:
+		return int64(v)
+
+	case uint64:
+		// only used by the decoder for values that don't fit in an int
+		return float64(v)
+	}
+
+	return v
+}
+
+// normalizeJSON converts the json.Numbers decoded from a JSON document into int64s if they're integers that fit, and
+// float64s otherwise.
+func normalizeJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, el := range v {
+			v[k] = normalizeJSON(el)
+		}
+		return v
+
+	case []interface{}:
+		for i, el := range v {
+			v[i] = normalizeJSON(el)
+		}
+		return v
+
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+
+		// integers that are too large are kept as float64s, and numbers too large for a float64 become infinite, which
+		// is reported as out of range when the value is parsed
+		f, _ := strconv.ParseFloat(string(v), 64)
+		return f
+	}
+
+	return v
+}
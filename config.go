@@ -1,53 +1,344 @@
 package config
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"sync"
+	"time"
 )
 
-var baseOptionSet OptionSet
+// Config holds a set of Options along with the information needed to build them: the files to search, the
+// metadata shown in Usage() and where that output goes. Each Config is independent, so a program can build more
+// than one configuration at a time.
+type Config struct {
+	// Name is the name of the application you're configuring.
+	Name string
+
+	// Description describes the application you're configuring.
+	Description string
+
+	// Version is the version of the application you're configuring.
+	Version string
+
+	// Examples contains a list of example commands and what they do.
+	Examples []Example
+
+	// SearchFiles contains a list of files which may or may not exist, and if they do, contain
+	// configuration files. The last entry in this list is parsed first, and its values are
+	// overwritten by values in files further up the list.
+	SearchFiles []SearchFile
+
+	// UsageWriter is the io.Writer to use for outputting Usage(). Defaults to stdout.
+	UsageWriter io.Writer
+
+	// EnvPrefix is the prefix used for the environment variables that set Options. If it's empty, the prefix is
+	// derived from Name.
+	EnvPrefix string
+
+	// WatchInterval is how often Watch checks the config files for changes. Defaults to 2 seconds.
+	WatchInterval time.Duration
+
+	// UnknownKeys sets how keys in config files that aren't the names of Options are handled. Defaults to
+	// IgnoreUnknownKeys.
+	UnknownKeys UnknownKeyMode
+
+	options  OptionSet
+	bindings []binding
+	rules    []Rule
+
+	// held while the Options are being loaded and replaced, so that builds, reloads and calls to Set don't
+	// overwrite each other's values
+	buildMu sync.Mutex
+
+	// held while the Options' values are being replaced, so that Snapshot sees either all of the old values or all of
+	// the new ones
+	valuesMu sync.RWMutex
+
+	// the Changes waiting to be passed to the Options' listeners, and whether a goroutine is passing them on
+	notifyMu  sync.Mutex
+	pending   []queuedChange
+	notifying bool
+
+	// the state of each Option that's changed since the changes were last reported to its listeners, like after a
+	// failed build, held with buildMu
+	unreported map[string]optionState
+
+	// the arguments and files used by the last build, which Watch uses to reload
+	mu          sync.Mutex
+	args        []string
+	searchFiles []SearchFile
+}
+
+// Result holds the outcome of a successful call to BuildArgs.
+type Result struct {
+	// Args holds the arguments that weren't used while building: flags that don't belong to an Option, followed
+	// by any positional arguments.
+	Args []string
+
+	// Warnings holds the errors for config files that exist but couldn't be read, and were skipped, and for unknown
+	// keys in config files when they're handled with WarnUnknownKeys.
+	Warnings []error
+}
+
+var (
+	// ErrHelpRequested is returned by BuildArgs after printing the usage information because -help or -h was passed.
+	ErrHelpRequested = errors.New("go-config: help requested")
+
+	// ErrDebugPrinted is returned by BuildArgs after printing the debug information requested with -config-debug.
+	ErrDebugPrinted = errors.New("go-config: debug information printed")
+
+	// ErrConfigWritten is returned by BuildArgs after exporting the configuration requested with -config-write.
+	ErrConfigWritten = errors.New("go-config: config written")
+)
+
+var baseConfig *Config
 
 func init() {
 	resetBaseOptionSet()
 	flag.Usage = func() {}
 }
 
+// New returns a new Config for the application named `name`, with the built-in options and the default
+// search files for that name.
+func New(name string) *Config {
+	c := &Config{
+		Name:        name,
+		Examples:    []Example{},
+		SearchFiles: defaultSearchFiles(name),
+		UsageWriter: os.Stdout,
+	}
+
+	c.resetOptions()
+
+	return c
+}
+
 func resetBaseOptionSet() {
-	baseOptionSet = make(OptionSet)
+	if baseConfig == nil {
+		baseConfig = New(Name)
+	}
+
+	baseConfig.resetOptions()
+}
+
+// defaultConfig returns the Config used by the package-level functions, updated with the package-level variables
+// so that code setting those continues to work. It's only used by the functions that build or print the Config, since
+// updating it isn't safe while the Options are being read; the others use baseConfig directly. The fields are updated
+// with the Config's locks held, so that a reload from Watch doesn't read them halfway through.
+func defaultConfig() *Config {
+	baseConfig.buildMu.Lock()
+	defer baseConfig.buildMu.Unlock()
+	baseConfig.mu.Lock()
+	defer baseConfig.mu.Unlock()
+
+	baseConfig.Name = Name
+	baseConfig.Description = Description
+	baseConfig.Version = Version
+	baseConfig.Examples = Examples
+	baseConfig.SearchFiles = SearchFiles
+	baseConfig.UsageWriter = UsageWriter
+	baseConfig.EnvPrefix = EnvPrefix
+	baseConfig.UnknownKeys = UnknownKeys
+
+	return baseConfig
+}
 
-	Add(Str("config-file", "", "A filename of an additional config file to use").SortOrder(998).builtIn())
-	Add(Bool("config-debug", false, "Show the files/scopes that are parsed and which scope each config value comes from").SortOrder(998).builtIn())
+func (c *Config) resetOptions() {
+	c.options = make(OptionSet)
+	c.bindings = nil
+	c.rules = nil
 
-	Add(Str("config-scope", "", "The scope that'll be written to").SortOrder(999).builtIn())
-	Add(Bool("config-partial", false, "Export a partial copy of the configuration, only what is explicitly passed in via flags").SortOrder(999).builtIn())
-	Add(Bool("config-save", false, "Export the configuration to the specified scope").SortOrder(999).builtIn())
-	Add(Bool("config-write", false, "Export the configuration to the specified scope, then exit").SortOrder(999).builtIn())
+	c.Add(Str("config-file", "", "A filename of an additional config file to use").SortOrder(998).builtIn())
+	c.Add(Str("config-debug", "", "Show each config value, its default and which scopes it comes from, as a table or (with -config-debug=json) as JSON").
+		AddFilter(isDebugFormat).SortOrder(998).builtIn().implicitValue("table"))
+
+	c.Add(Str("config-scope", "", "The scope that'll be written to").SortOrder(999).builtIn())
+	c.Add(Bool("config-partial", false, "Export a partial copy of the configuration, only what is explicitly passed in via flags").SortOrder(999).builtIn())
+	c.Add(Bool("config-save", false, "Export the configuration to the specified scope").SortOrder(999).builtIn())
+	c.Add(Bool("config-write", false, "Export the configuration to the specified scope, then exit").SortOrder(999).builtIn())
 }
 
-// Add adds an Option to the config's OptionSet
-func Add(o *Option) *Option {
-	baseOptionSet.Add(o)
+// Options returns the Config's OptionSet.
+func (c *Config) Options() OptionSet {
+	return c.options
+}
+
+// Add adds an Option to the Config's OptionSet
+func (c *Config) Add(o *Option) *Option {
+	c.options.Add(o)
 	return o
 }
 
-// Build builds the configuration object. Starts by setting the default values as defined in code, then parses the config file,
-// then loads the overridden options from flag. If set, this also exports the as-run configuration to the the filename
-// set in the "config" option.
-func Build() error {
+// Build builds the configuration object using os.Args. Starts by setting the default values as defined in code, then parses
+// the config files, then the environment variables, then loads the overridden options from flag. If set, this also exports the as-run configuration to the
+// the filename set in the "config" option.
+//
+// Unlike BuildArgs, Build exits the program after -help, -config-debug and -config-write, or if the built-in flags can't
+// be parsed, and replaces os.Args with the arguments it didn't use before passing them on to the flag package.
+func (c *Config) Build() error {
+	res, err := c.BuildArgs(os.Args[1:])
+	switch err {
+	case nil:
+	case ErrHelpRequested, ErrDebugPrinted, ErrConfigWritten:
+		os.Exit(0)
+		return nil
+	default:
+		if _, ok := err.(BuiltInFlagError); ok {
+			os.Exit(2)
+		}
+
+		if _, ok := err.(MultiError); ok {
+			fmt.Println("Error:", err.Error())
+		}
+		return err
+	}
+
+	for _, w := range res.Warnings {
+		if ioerr, ok := w.(IOError); ok {
+			fmt.Fprintf(os.Stderr, "go-config: error parsing config file: %s\n", ioerr.err)
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "go-config: warning: %s\n", w)
+	}
+
+	os.Args = append([]string{os.Args[0]}, res.Args...)
+	return flag.CommandLine.Parse(res.Args)
+}
+
+// BuildArgs builds the configuration object like Build, but uses `args` (which shouldn't include the program name) instead
+// of os.Args. It never exits the program: after -help, -config-debug or -config-write it returns ErrHelpRequested,
+// ErrDebugPrinted or ErrConfigWritten, and the caller decides what to do next. The arguments that weren't used are
+// returned in the Result instead of being written back to os.Args.
+func (c *Config) BuildArgs(args []string) (*Result, error) {
 	var err error
 
+	defer c.notify()
+
+	c.buildMu.Lock()
+	defer c.buildMu.Unlock()
+
+	// load into a copy so that the Options can be read while they're being loaded. The loaded values are kept even
+	// if there's an error, like they always have been.
+	staged := c.options.clone()
+	l, err := c.load(staged, args)
+
+	// the new values are only reported to the listeners once they're valid
+	c.commit(staged)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.args = args
+	c.searchFiles = l.searchFiles
+	c.mu.Unlock()
+
+	if l.flags.HasHelpFlag() {
+		c.Usage()
+		return nil, ErrHelpRequested
+	}
+
+	err = c.checkRequired(c.options, l.searchFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	// validate all options that are required
+	err = c.options.Validate(c.rules...)
+	if err != nil {
+		return nil, err
+	}
+
+	c.report()
+
+	if format := debugFormat(c.Require("config-debug").Str()); format != "" {
+		err = c.DebugReport().Write(c.UsageWriter, format)
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrDebugPrinted
+	}
+
+	c.options.fillVars()
+	err = c.fillBindings()
+	if err != nil {
+		return nil, err
+	}
+
+	// export new config to file if necessary
+	if c.Require("config-save").Bool() || c.Require("config-write").Bool() {
+		err = c.writeScope(l.searchFiles, c.Require("config-scope").Str())
+		if err != nil {
+			return nil, err
+		}
+
+		if c.Require("config-write").Bool() {
+			return nil, ErrConfigWritten
+		}
+	}
+
+	return &Result{
+		Args:     l.flags.Release()[1:],
+		Warnings: l.warnings,
+	}, nil
+}
+
+// checkRequired returns an error listing every Required Option in `options` whose value wasn't set by a config file,
+// an environment variable or a flag.
+func (c *Config) checkRequired(options OptionSet, searchFiles []SearchFile) error {
+	names := []string{}
+	for k, v := range options {
+		if v.Options.Required && !isSet(v) {
+			names = append(names, k)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+
+	err := RequiredError{Files: searchFiles}
+	for _, k := range names {
+		err.Missing = append(err.Missing, MissingOption{Name: k, EnvVar: c.EnvName(options[k])})
+	}
+
+	return err
+}
+
+// loaded holds what was found while loading an OptionSet.
+type loaded struct {
+	searchFiles []SearchFile
+	flags       FlagSet
+	warnings    []error
+}
+
+// load sets `options` back to their defaults, then applies the config files, the environment and the flags in `args`
+// to them, in that order.
+func (c *Config) load(options OptionSet, args []string) (*loaded, error) {
+	var err error
+
+	// start from the defaults, so that building more than once doesn't carry values over from the last build
+	for _, v := range options {
+		v.reset()
+	}
+
 	// parse flags
-	fs := NewFlagSet(os.Args[0], os.Args[1:])
-	perr := fs.ParseBuiltIn()
-	if perr != nil {
-		os.Exit(2)
+	fs := newFlagSet(c.Name, args, options)
+	err = fs.ParseBuiltIn()
+	if err != nil {
+		return nil, BuiltInFlagError{err}
 	}
 
-	searchFiles := make([]SearchFile, len(SearchFiles))
-	copy(searchFiles, SearchFiles)
+	searchFiles := make([]SearchFile, len(c.SearchFiles))
+	copy(searchFiles, c.SearchFiles)
 
-	overrideName := Require("config-file").String()
+	overrideName := options.Require("config-file").String()
 	if overrideName != "" {
 		searchFiles = append([]SearchFile{{
 			Scope: "custom",
@@ -56,103 +347,103 @@ func Build() error {
 	}
 
 	// find all the config files, import them
+	warnings, err := c.readFiles(options, searchFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	// environment variables override the config files, but not the flags
+	err = c.readEnv(options)
+	if err != nil {
+		return nil, err
+	}
+
+	fs = newFlagSet(c.Name, args, options)
+	err = fs.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return &loaded{
+		searchFiles: searchFiles,
+		flags:       fs,
+		warnings:    warnings,
+	}, nil
+}
+
+// readFiles reads each of the SearchFiles that exists into `options`, starting with the last one. Files that exist but
+// can't be read are skipped, and returned as warnings.
+func (c *Config) readFiles(options OptionSet, searchFiles []SearchFile) ([]error, error) {
+	warnings := []error{}
 	for i := len(searchFiles) - 1; i >= 0; i-- {
-		// fmt.Println("Parsing", os.ExpandEnv(searchFiles[i]))
 		file := FileIO{
-			filename: searchFiles[i].ExpandedPath(),
-			scope:    searchFiles[i].Scope,
+			filename:  searchFiles[i].ExpandedPath(),
+			format:    searchFiles[i].Format,
+			scope:     searchFiles[i].Scope,
+			options:   options,
+			envPrefix: c.envPrefix(),
+
+			unknownKeys: c.unknownKeyMode(searchFiles[i]),
 		}
-		err = file.Read()
+		err := file.Read()
 		if err != nil {
+			if unknown, ok := err.(unknownKeyWarnings); ok {
+				for _, w := range unknown {
+					warnings = append(warnings, w)
+				}
+				continue
+			}
+
 			if ioerr, ok := err.(IOError); ok {
 				if ioerr.Type == "exist" {
 					continue
 				}
 
-				fmt.Fprintf(os.Stderr, "go-config: error parsing config file: %s\n", ioerr.err)
+				warnings = append(warnings, ioerr)
 				continue
 			}
 
-			if _, ok := err.(jsonConfigMapParseErrorList); ok {
-				fmt.Println("Error:", err.Error())
-				return err.(jsonConfigMapParseErrorList)
+			if _, ok := err.(MultiError); ok {
+				return nil, err
 			}
 
-			fmt.Println("Error:", err.Error())
-			return fmt.Errorf("Error building config file: %s", err)
+			return nil, fmt.Errorf("Error building config file: %s", err)
 		}
-
-		// fmt.Println(baseOptionSet)
-	}
-
-	fs = NewFlagSet(os.Args[0], os.Args[1:])
-	perr = fs.Parse()
-	if perr != nil {
-		return perr
 	}
 
-	if fs.HasHelpFlag() {
-		Usage()
-		os.Exit(0)
-		return nil
-	}
-
-	// validate all options that are required
-	err = baseOptionSet.Validate()
-	if err != nil {
-		return err
-	}
+	return warnings, nil
+}
 
-	if Require("config-debug").Bool() {
-		for _, v := range baseOptionSet {
-			if !v.isBuiltIn {
-				fmt.Println(v.DebugString())
+// writeScope exports the Config's OptionSet to the SearchFile with the given scope.
+func (c *Config) writeScope(searchFiles []SearchFile, scope string) error {
+	found := false
+	for _, v := range searchFiles {
+		if v.Scope == scope {
+			found = true
+			file := FileIO{
+				filename: v.ExpandedPath(),
+				format:   v.Format,
+				scope:    scope,
+				options:  c.options,
 			}
-		}
-		os.Exit(0)
-		return nil
-	}
-
-	// export new config to file if necessary
-	if Require("config-save").Bool() || Require("config-write").Bool() {
-
-		scope := Require("config-scope").Str()
-
-		found := false
-		for _, v := range searchFiles {
-			if v.Scope == scope {
-				found = true
-				file := FileIO{
-					filename: v.ExpandedPath(),
-					scope:    scope,
-				}
-				err := file.Write()
-				if err != nil {
-					return fmt.Errorf("go-config: can't write to file: %s", err)
-				}
-				if Require("config-write").Bool() {
-					os.Exit(0)
-				}
+			err := file.Write()
+			if err != nil {
+				return fmt.Errorf("go-config: can't write to file: %s", err)
 			}
 		}
-
-		if !found {
-			return fmt.Errorf("go-config: can't find a config file with the scope %s", scope)
-		}
 	}
 
-	os.Args = fs.Release()
-	if len(os.Args) > 0 {
-		err = flag.CommandLine.Parse(os.Args[1:])
+	if !found {
+		return fmt.Errorf("go-config: can't find a config file with the scope %s", scope)
 	}
 
-	return err
+	return nil
 }
 
 // Require looks for an Option with the name of `key`. If no Option is found, this function panics.
-func Require(key string) *Option {
+func (c *Config) Require(key string) *Option {
 
-	s, err := Get(key)
+	s, err := c.Get(key)
 
 	if err != nil {
 		panic(err)
@@ -162,9 +453,9 @@ func Require(key string) *Option {
 }
 
 // Get looks for an Option with the name of `key`. If no Option is found, this function returns an error.
-func Get(key string) (*Option, error) {
+func (c *Config) Get(key string) (*Option, error) {
 
-	s, exists := baseOptionSet.Get(key)
+	s, exists := c.options.Get(key)
 
 	if !exists {
 		return nil, fmt.Errorf("config option with key %s not found", key)
@@ -172,3 +463,83 @@ func Get(key string) (*Option, error) {
 
 	return s, nil
 }
+
+// Set parses `val` as the value of the Option named `key` while the program is running, with the scope "runtime". The
+// new value is only kept if all of the Options are still valid, and lasts until the Config is built or reloaded again.
+// It's safe to call while other goroutines are reading the Options. Variables passed to Var and structs bound with
+// BindStruct aren't changed.
+func (c *Config) Set(key string, val string) error {
+	defer c.notify()
+
+	c.buildMu.Lock()
+	defer c.buildMu.Unlock()
+
+	staged := c.options.clone()
+
+	opt, exists := staged.Get(key)
+	if !exists {
+		return fmt.Errorf("config option with key %s not found", key)
+	}
+
+	err := opt.setFromString(val, "")
+	if err != nil {
+		return err
+	}
+	opt.addProvenance(Provenance{Source: RuntimeSource, Scope: "runtime"})
+
+	err = staged.Validate(c.rules...)
+	if err != nil {
+		return err
+	}
+
+	c.commit(staged)
+	c.report()
+
+	return nil
+}
+
+// Snapshot returns a copy of the Config's Options as they are now. Reading a single Option is always safe while the
+// Config is being built, reloaded or set, but reading several one after another can see some of them before a change
+// and some after it; the Options in a Snapshot all come from the same build, reload or call to Set. The copies don't
+// change when the Config does.
+func (c *Config) Snapshot() OptionSet {
+	c.valuesMu.RLock()
+	defer c.valuesMu.RUnlock()
+
+	return c.options.clone()
+}
+
+// Add adds an Option to the default Config's OptionSet
+func Add(o *Option) *Option {
+	return baseConfig.Add(o)
+}
+
+// Build builds the default Config. See (*Config).Build.
+func Build() error {
+	return defaultConfig().Build()
+}
+
+// BuildArgs builds the default Config from `args` without exiting the program. See (*Config).BuildArgs.
+func BuildArgs(args []string) (*Result, error) {
+	return defaultConfig().BuildArgs(args)
+}
+
+// Require looks for an Option with the name of `key` in the default Config. If no Option is found, this function panics.
+func Require(key string) *Option {
+	return baseConfig.Require(key)
+}
+
+// Get looks for an Option with the name of `key` in the default Config. If no Option is found, this function returns an error.
+func Get(key string) (*Option, error) {
+	return baseConfig.Get(key)
+}
+
+// Set sets the Option named `key` in the default Config while the program is running. See (*Config).Set.
+func Set(key string, val string) error {
+	return baseConfig.Set(key, val)
+}
+
+// Snapshot returns a copy of the default Config's Options as they are now. See (*Config).Snapshot.
+func Snapshot() OptionSet {
+	return baseConfig.Snapshot()
+}
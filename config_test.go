@@ -6,13 +6,22 @@ import (
 	"testing"
 
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"math"
+	"net"
 	"os"
 	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var originalOSArgs []string
@@ -73,7 +82,8 @@ func resetArgs() {
 }
 
 func writeToTemporaryFile(t *testing.T, out []byte, filepath string) {
-	fp, err := os.OpenFile(filepath, os.O_RDWR+os.O_CREATE+os.O_TRUNC, 0644)
+	// write to a new file and move it into place, so that Watch doesn't report a half-written file as an error
+	fp, err := os.OpenFile(filepath+".tmp", os.O_RDWR+os.O_CREATE+os.O_TRUNC, 0644)
 	if err != nil {
 		t.Errorf("Couldn't open temporary config file at %s: %s", filepath, err)
 		t.FailNow()
@@ -81,6 +91,12 @@ func writeToTemporaryFile(t *testing.T, out []byte, filepath string) {
 
 	fp.Write(out)
 	fp.Close()
+
+	err = os.Rename(filepath+".tmp", filepath)
+	if err != nil {
+		t.Errorf("Couldn't move temporary config file to %s: %s", filepath, err)
+		t.FailNow()
+	}
 }
 
 func readFromTemporaryFile(t *testing.T, filepath string) []byte {
@@ -122,13 +138,19 @@ func TestBasicConfigLoad(t *testing.T) {
 
 	// and here we go!
 	err = Build()
-	assert.EqualError(t, err, jsonConfigMapParseErrorList([]jsonConfigMapError{
-		jsonConfigMapParseError{
-			key:      "bad_string",
-			got:      float64(8.5),
-			expected: StringType,
+	assert.EqualError(t, err, MultiError{
+		Errors: []error{
+			TypeError{
+				Key:      "bad_string",
+				Expected: StringType,
+				Actual:   "float64",
+				Value:    float64(8.5),
+				From:     Provenance{Source: FileSource, Scope: "app", Path: filepath, Line: 6, Column: 19},
+			},
 		},
-	}).Error())
+		heading: "error(s) parsing config file " + filepath,
+	}.Error())
+	assert.Contains(t, err.Error(), filepath+":6:19: ", "The error should say where the value is")
 
 	a := Require("addend.a").Int()
 	assert.Equal(t, a, int64(10), "addend.a should be 10")
@@ -245,10 +267,10 @@ func TestErroredConfigLoad(t *testing.T) {
 
 	// and here we go!
 	genericErr := Build()
-	buildErr, ok := genericErr.(jsonConfigMapParseErrorList)
+	buildErr, ok := genericErr.(MultiError)
 	_ = ok
 
-	require.IsType(t, jsonConfigMapParseErrorList{}, buildErr, true, "Build() should return a jsonConfigMapParseErrorList, instead %T", genericErr)
+	require.IsType(t, MultiError{}, buildErr, true, "Build() should return a MultiError, instead %T", genericErr)
 	assert.Equal(t, 4, buildErr.Len(), "There should be 4 build errors")
 }
 
@@ -441,7 +463,7 @@ func TestConfigWrite(t *testing.T) {
 
 	Build()
 
-	builtJSON, _ := json.Marshal(baseOptionSet.Export(false, true))
+	builtJSON, _ := json.Marshal(baseConfig.options.Export(false, true))
 	buf := bytes.Buffer{}
 	json.Compact(&buf, newConfigJSON)
 
@@ -754,3 +776,1830 @@ func TestCascadingConfigFile(t *testing.T) {
 	assert.Equal(t, false, subtract, "subtract should be false")
 	assert.Equal(t, "App config file", name, "name should be App config file")
 }
+
+func TestIndependentConfigs(t *testing.T) {
+	var err error
+	var appFilePath = tempAppDir + "/config.json"
+	var userFilePath = tempUserDir + "/config.json"
+
+	writeToTemporaryFile(t, []byte(`{"name": "App config file", "addend": {"a": 2}}`), appFilePath)
+	writeToTemporaryFile(t, []byte(`{"name": "User config file", "addend": {"a": 1}}`), userFilePath)
+	resetBaseOptionSet()
+
+	resetArgs()
+
+	app := New("app")
+	app.SearchFiles = []SearchFile{{Scope: "app", Path: appFilePath}}
+	app.Add(Int("addend.a", 0, "The first addend").Exportable(true))
+	app.Add(Str("name", "", "Name of the example").Exportable(true))
+
+	user := New("user")
+	user.SearchFiles = []SearchFile{{Scope: "user", Path: userFilePath}}
+	user.Add(Int("addend.a", 0, "The first addend").Exportable(true))
+	user.Add(Str("name", "", "Name of the example").Exportable(true))
+
+	err = app.Build()
+	assert.Nil(t, err, "There is no error here")
+
+	err = user.Build()
+	assert.Nil(t, err, "There is no error here")
+
+	assert.Equal(t, int64(2), app.Require("addend.a").Int(), "app's addend.a should be 2")
+	assert.Equal(t, "App config file", app.Require("name").Str(), "app's name should be App config file")
+
+	assert.Equal(t, int64(1), user.Require("addend.a").Int(), "user's addend.a should be 1")
+	assert.Equal(t, "User config file", user.Require("name").Str(), "user's name should be User config file")
+
+	_, err = app.Get("invalid-parameter")
+	assert.NotNil(t, err, "Get(\"invalid-parameter\") should return an error")
+
+	_, err = Get("name")
+	assert.NotNil(t, err, "The default Config shouldn't have any of the options added to other Configs")
+}
+
+func TestBuildArgs(t *testing.T) {
+	var filepath = tempAppDir + "/config.json"
+
+	writeToTemporaryFile(t, []byte(`{"addend": {"a": 1}}`), filepath)
+	resetBaseOptionSet()
+	resetArgs()
+
+	Add(Int("addend.a", 0, "The first addend").Exportable(true))
+	Add(Bool("subtract", false, "Subtract instead of add").Exportable(true))
+
+	res, err := BuildArgs([]string{
+		`-addend.a=4`,
+		`-unknown=value`,
+		`-subtract`,
+		`positional`,
+	})
+	require.Nil(t, err, "There is no error here")
+
+	assert.Equal(t, int64(4), Require("addend.a").Int(), "addend.a should be 4")
+	assert.Equal(t, true, Require("subtract").Bool(), "subtract should be true")
+	assert.Equal(t, []string{`-unknown=value`, `positional`}, res.Args, "Unused arguments should be returned")
+	assert.Equal(t, originalOSArgs, os.Args, "os.Args shouldn't be modified")
+
+	_, err = BuildArgs([]string{`-help`})
+	assert.Equal(t, ErrHelpRequested, err, "BuildArgs should return ErrHelpRequested")
+
+	_, err = BuildArgs([]string{`-config-debug`})
+	assert.Equal(t, ErrDebugPrinted, err, "BuildArgs should return ErrDebugPrinted")
+
+	_, err = BuildArgs([]string{`-config-write`, `-config-scope=app`, `-addend.a=5`})
+	assert.Equal(t, ErrConfigWritten, err, "BuildArgs should return ErrConfigWritten")
+
+	written := readFromTemporaryFile(t, filepath)
+	assert.Contains(t, string(written), `"a": 5`, "Written config file should contain the new value")
+}
+
+func TestEnvConfigLoad(t *testing.T) {
+	var err error
+	var filepath = tempAppDir + "/config.json"
+
+	writeToTemporaryFile(t, []byte(`{"addend": {"a": 1, "b": 1}, "name": "Config file"}`), filepath)
+	resetBaseOptionSet()
+	resetArgs()
+
+	EnvPrefix = "CONFIGTEST"
+	defer func() { EnvPrefix = "" }()
+
+	t.Setenv("CONFIGTEST_ADDEND_A", "2")
+	t.Setenv("CONFIGTEST_ADDEND_B", "2")
+	t.Setenv("CONFIGTEST_SUBTRACT", "true")
+	t.Setenv("EXAMPLE_NAME", "Environment")
+
+	Add(Int("addend.a", 0, "The first addend").Exportable(true))
+	Add(Int("addend.b", 0, "The second addend").Exportable(true))
+	Add(Bool("subtract", false, "Subtract the arguments").Exportable(true))
+	Add(Str("name", "", "Name of the example").Exportable(true).EnvVar("EXAMPLE_NAME"))
+
+	_, err = BuildArgs([]string{`-addend.b=3`})
+	assert.Nil(t, err, "There is no error here")
+
+	assert.Equal(t, int64(2), Require("addend.a").Int(), "addend.a should come from the environment")
+	assert.Equal(t, int64(3), Require("addend.b").Int(), "addend.b should come from the flag")
+	assert.Equal(t, true, Require("subtract").Bool(), "subtract should come from the environment")
+	assert.Equal(t, "Environment", Require("name").Str(), "name should come from the overridden environment variable")
+	assert.True(t, Require("addend.a").HasScope("env"), "addend.a should have the env scope")
+
+	t.Setenv("CONFIGTEST_SUBTRACT", "maybe")
+	_, err = BuildArgs([]string{})
+	assert.NotNil(t, err, "An invalid environment variable should be an error")
+}
+
+func TestBindStruct(t *testing.T) {
+	var err error
+	var filepath = tempAppDir + "/config.json"
+
+	writeToTemporaryFile(t, []byte(`{"addend": {"a": 4, "b": 2.5}, "name": "Test"}`), filepath)
+	resetBaseOptionSet()
+	resetArgs()
+
+	type appConfig struct {
+		Addend struct {
+			A int64   `config:"a" default:"10" desc:"The first addend" export:"true"`
+			B float64 `config:"b" desc:"The second addend" export:"true"`
+		}
+		Subtract bool              `desc:"Subtract instead of add"`
+		Name     string            `config:"name" default:"Basic Example"`
+		Workers  int8              `config:"workers" default:"4"`
+		Ports    []int             `config:"ports" default:"80,443"`
+		Labels   map[string]string `config:"labels" default:"env=dev"`
+		Ignored  string            `config:"-"`
+	}
+
+	cfg := appConfig{}
+	cfg.Addend.B = math.Pi
+
+	err = BindStruct(&cfg)
+	require.Nil(t, err, "There is no error binding the struct")
+
+	assert.Equal(t, int64(10), Require("addend.a").DefaultValue, "addend.a should default to 10")
+	assert.Equal(t, math.Pi, Require("addend.b").DefaultValue, "addend.b should default to the field's value")
+	assert.True(t, Require("addend.a").Options.Exportable, "addend.a should be exportable")
+
+	_, err = Get("ignored")
+	assert.NotNil(t, err, "Ignored shouldn't be bound")
+
+	_, err = BuildArgs([]string{`-subtract`})
+	require.Nil(t, err, "There is no error here")
+
+	assert.Equal(t, int64(4), cfg.Addend.A, "Addend.A should be 4")
+	assert.Equal(t, 2.5, cfg.Addend.B, "Addend.B should be 2.5")
+	assert.Equal(t, true, cfg.Subtract, "Subtract should be true")
+	assert.Equal(t, "Test", cfg.Name, "Name should be Test")
+	assert.Equal(t, int8(4), cfg.Workers, "Workers should be 4")
+	assert.Equal(t, []int{80, 443}, cfg.Ports, "Ports should be 80 and 443")
+	assert.Equal(t, map[string]string{"env": "dev"}, cfg.Labels, "Labels should be env=dev")
+
+	require.Nil(t, Set("name", "Runtime"), "There is no error here")
+	assert.Equal(t, "Runtime", Require("name").Str(), "name should be set")
+	assert.Equal(t, "Test", cfg.Name, "Set shouldn't change the bound struct")
+
+	_, err = BuildArgs([]string{`-workers=300`})
+	assert.EqualError(t, err, "go-config: error(s) filling bound struct:\n  appConfig.Workers: value 300 of workers overflows int8")
+
+	var bindErr BindError
+	require.True(t, errors.As(err, &bindErr), "An overflowing field should be a BindError")
+	assert.Equal(t, "appConfig.Workers", bindErr.Field)
+	assert.Equal(t, "workers", bindErr.Option)
+	assert.Equal(t, reflect.TypeOf(int8(0)), bindErr.Type)
+
+	bad := struct {
+		A int `config:"a" default:"ten"`
+	}{}
+	err = New("bad").BindStruct(&bad)
+	assert.EqualError(t, err, `go-config: struct.A: invalid default "ten": expected int64`)
+}
+
+func TestDurationConfig(t *testing.T) {
+	var err error
+	var filepath = tempAppDir + "/config.json"
+
+	writeToTemporaryFile(t, []byte(`{"timeout": "1m30s", "interval": 2.5, "retry": "1s"}`), filepath)
+	resetBaseOptionSet()
+	resetArgs()
+
+	Add(Duration("timeout", time.Second, "How long to wait").Exportable(true))
+	Add(Duration("interval", time.Second, "How often to check").Exportable(true))
+	Add(Duration("retry", time.Second, "How long to wait between retries").Exportable(true))
+
+	_, err = BuildArgs([]string{`-retry=250ms`})
+	require.Nil(t, err, "There is no error here")
+
+	assert.Equal(t, 90*time.Second, Require("timeout").Duration(), "timeout should be 1m30s")
+	assert.Equal(t, 2500*time.Millisecond, Require("interval").Duration(), "interval should be 2.5s")
+	assert.Equal(t, 250*time.Millisecond, Require("retry").Duration(), "retry should be 250ms")
+
+	_, err = BuildArgs([]string{`-config-write`, `-config-scope=app`})
+	assert.Equal(t, ErrConfigWritten, err, "BuildArgs should return ErrConfigWritten")
+
+	_, err = BuildArgs([]string{})
+	require.Nil(t, err, "The written config should be read back without errors")
+	assert.Equal(t, 90*time.Second, Require("timeout").Duration(), "timeout should round-trip")
+	assert.Equal(t, 2500*time.Millisecond, Require("interval").Duration(), "interval should round-trip")
+
+	written := readFromTemporaryFile(t, filepath)
+	assert.Contains(t, string(written), `"timeout": "1m30s"`, "Durations should be written as strings")
+
+	writeToTemporaryFile(t, []byte(`{"timeout": "soon"}`), filepath)
+	_, err = BuildArgs([]string{})
+	assert.IsType(t, MultiError{}, err, "An invalid duration should be a parse error")
+
+	for _, in := range []string{`{"timeout": 9999999999999}`, `{"timeout": 1e300}`, `{"timeout": -1e300}`} {
+		writeToTemporaryFile(t, []byte(in), filepath)
+		_, err = BuildArgs([]string{})
+
+		var parseErr ParseError
+		require.True(t, errors.As(err, &parseErr), "A number of seconds that doesn't fit should be a ParseError: %s", in)
+		assert.Equal(t, "timeout", parseErr.Key)
+		assert.True(t, errors.Is(err, strconv.ErrRange), "The error should wrap strconv.ErrRange: %s", in)
+	}
+}
+
+func TestSliceConfig(t *testing.T) {
+	var err error
+	var appFilePath = tempAppDir + "/config.json"
+	var userFilePath = tempUserDir + "/config.json"
+
+	writeToTemporaryFile(t, []byte(`{"hosts": ["a.example.com", "b.example.com"], "ports": [80, 443], "weights": "0.5, 1.5", "tags": ["app"]}`), appFilePath)
+	writeToTemporaryFile(t, []byte(`{"hosts": ["user.example.com"], "tags": ["user"]}`), userFilePath)
+	resetBaseOptionSet()
+	resetArgs()
+
+	Add(StrSlice("hosts", []string{"localhost"}, "Allowed hosts").Exportable(true))
+	Add(IntSlice("ports", nil, "Ports to listen on").Exportable(true))
+	Add(FloatSlice("weights", nil, "Upstream weights").Exportable(true))
+	Add(StrSlice("tags", nil, "Tags").Exportable(true).Append(true))
+
+	_, err = BuildArgs([]string{})
+	require.Nil(t, err, "There is no error here")
+
+	assert.Equal(t, []string{"a.example.com", "b.example.com"}, Require("hosts").StrSlice(), "The app file should replace the user file's hosts")
+	assert.Equal(t, []int64{80, 443}, Require("ports").IntSlice(), "ports should come from the JSON array")
+	assert.Equal(t, []float64{0.5, 1.5}, Require("weights").FloatSlice(), "weights should come from the comma-separated string")
+	assert.Equal(t, []string{"user", "app"}, Require("tags").StrSlice(), "tags should be appended across scopes")
+
+	_, err = BuildArgs([]string{`-hosts`, `c.example.com`, `-hosts=d.example.com,e.example.com`, `-tags=flag`})
+	require.Nil(t, err, "There is no error here")
+
+	assert.Equal(t, []string{"c.example.com", "d.example.com", "e.example.com"}, Require("hosts").StrSlice(), "Repeated flags should be added together")
+	assert.Equal(t, []string{"user", "app", "flag"}, Require("tags").StrSlice(), "tags should be appended across scopes")
+	assert.Equal(t, []string{"localhost"}, Require("hosts").DefaultValue, "The default value shouldn't change")
+	assert.Equal(t, "localhost", Require("hosts").DefaultValueString(), "The default value should be displayed as a list")
+
+	exported := baseConfig.options.Export(false, true)
+	assert.Equal(t, []int64{80, 443}, exported["ports"], "Slices should be exported")
+
+	writeToTemporaryFile(t, []byte(`{"ports": [80, "443"]}`), appFilePath)
+	_, err = BuildArgs([]string{})
+	assert.IsType(t, MultiError{}, err, "A string in an int array should be a parse error")
+}
+
+func TestStrMapConfig(t *testing.T) {
+	var err error
+	var appFilePath = tempAppDir + "/config.json"
+	var userFilePath = tempUserDir + "/config.json"
+
+	writeToTemporaryFile(t, []byte(`{"labels": {"team": "core", "env": "prod"}}`), appFilePath)
+	writeToTemporaryFile(t, []byte(`{"labels": {"owner": "me", "env": "dev"}}`), userFilePath)
+	resetBaseOptionSet()
+	resetArgs()
+
+	Add(StrMap("labels", map[string]string{"app": "go-config"}, "Labels to attach").Exportable(true))
+
+	_, err = BuildArgs([]string{`-labels`, `region=us-east`, `-labels=tier=web,env=test`})
+	require.Nil(t, err, "There is no error here")
+
+	assert.Equal(t, map[string]string{
+		"app":    "go-config",
+		"owner":  "me",
+		"team":   "core",
+		"env":    "test",
+		"region": "us-east",
+		"tier":   "web",
+	}, Require("labels").StrMap(), "labels should be merged key by key across scopes")
+	assert.Equal(t, map[string]string{"app": "go-config"}, Require("labels").DefaultValue, "The default value shouldn't change")
+	assert.Equal(t, "app=go-config", Require("labels").DefaultValueString(), "The default value should be displayed as key=value pairs")
+
+	_, err = BuildArgs([]string{`-labels=invalid`})
+	assert.NotNil(t, err, "A flag without a key=value pair should be an error")
+
+	writeToTemporaryFile(t, []byte(`{"labels": {"team": 5}}`), appFilePath)
+	_, err = BuildArgs([]string{})
+	assert.IsType(t, MultiError{}, err, "A non-string label should be a parse error")
+}
+
+type testLogLevel int
+
+func (l *testLogLevel) Set(s string) error {
+	switch s {
+	case "debug":
+		*l = 0
+	case "info":
+		*l = 1
+	case "error":
+		*l = 2
+	default:
+		return fmt.Errorf("unknown log level %q", s)
+	}
+	return nil
+}
+
+func (l *testLogLevel) String() string {
+	return []string{"debug", "info", "error"}[*l]
+}
+
+type testVerbose bool
+
+func (v *testVerbose) Set(s string) error {
+	*v = s == "true"
+	return nil
+}
+
+func (v *testVerbose) String() string {
+	return fmt.Sprintf("%v", bool(*v))
+}
+
+func (v *testVerbose) IsBoolFlag() bool { return true }
+
+// testHosts is a list that each flag adds to, like the example in the flag package's documentation.
+type testHosts []string
+
+func (h *testHosts) Set(s string) error {
+	*h = append(*h, s)
+	return nil
+}
+
+func (h *testHosts) String() string {
+	return strings.Join(*h, ",")
+}
+
+// testLabels is set through a map rather than a pointer to one.
+type testLabels map[string]string
+
+func (l testLabels) Set(s string) error {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 {
+		return fmt.Errorf("%q isn't a key=value pair", s)
+	}
+	l[kv[0]] = kv[1]
+	return nil
+}
+
+func (l testLabels) String() string {
+	return fmt.Sprintf("%v", map[string]string(l))
+}
+
+func TestVarConfig(t *testing.T) {
+	var err error
+	var filepath = tempAppDir + "/config.json"
+
+	writeToTemporaryFile(t, []byte(`{"log-level": "error", "bind": "10.0.0.1"}`), filepath)
+	resetBaseOptionSet()
+	resetArgs()
+
+	level := testLogLevel(1)
+	bind := net.ParseIP("127.0.0.1")
+	verbose := testVerbose(false)
+
+	Add(Var("log-level", &level, "The log level").Exportable(true))
+	Add(Var("bind", &bind, "The IP address to bind to").Exportable(true))
+	Add(Var("verbose", &verbose, "Verbose output"))
+
+	assert.Equal(t, "info", Require("log-level").DefaultValueString(), "The default should be the value's text")
+
+	_, err = BuildArgs([]string{})
+	require.Nil(t, err, "There is no error here")
+
+	assert.Equal(t, testLogLevel(2), level, "log-level should be set from the config file")
+	assert.Equal(t, "10.0.0.1", bind.String(), "bind should be set from the config file")
+
+	_, err = BuildArgs([]string{`-log-level=debug`, `-verbose`})
+	require.Nil(t, err, "There is no error here")
+
+	assert.Equal(t, testLogLevel(0), level, "log-level should be set from the flag")
+	assert.Equal(t, testVerbose(true), verbose, "verbose should be set without a value")
+
+	exported := baseConfig.options.Export(false, true)
+	assert.Equal(t, "debug", exported["log-level"], "Custom values should be exported as text")
+	assert.Equal(t, "10.0.0.1", exported["bind"], "Custom values should be exported as text")
+
+	_, err = BuildArgs([]string{`-log-level=verbose`})
+	assert.NotNil(t, err, "An invalid log level should be an error")
+
+	var hosts testHosts
+	Add(Var("hosts", &hosts, "Hosts to connect to"))
+
+	for i := 0; i < 2; i++ {
+		_, err = BuildArgs([]string{`-hosts`, `a`, `-hosts`, `b`})
+		require.Nil(t, err, "There is no error here")
+		assert.Equal(t, testHosts{"a", "b"}, hosts, "Each build should start from the default")
+	}
+
+	require.Nil(t, Set("hosts", "c"), "There is no error here")
+	assert.Equal(t, "a,b,c", Require("hosts").String(), "Set should add to the Option's value")
+	assert.Equal(t, testHosts{"a", "b"}, hosts, "Set shouldn't change the variable")
+
+	_, err = BuildArgs([]string{})
+	require.Nil(t, err, "There is no error here")
+	assert.Empty(t, hosts, "hosts should go back to its default")
+
+	assert.Panics(t, func() {
+		Var("invalid", testLabels{}, "Not a pointer")
+	})
+
+	assert.Panics(t, func() {
+		Var("invalid", 5, "Not a flag.Value")
+	}, "Var should panic if the value can't be set")
+}
+
+func TestYAMLConfigLoad(t *testing.T) {
+	var err error
+	var appFilePath = tempAppDir + "/config.yaml"
+	var userFilePath = tempUserDir + "/config.yml"
+
+	writeToTemporaryFile(t, []byte(`
+addend:
+  a: 4
+  b: 2.5
+name: YAML config file
+timeout: 1m30s
+hosts:
+  - a.example.com
+  - b.example.com
+labels:
+  team: core
+`), appFilePath)
+	writeToTemporaryFile(t, []byte("subtract: true\nlabels:\n  env: dev\n"), userFilePath)
+	resetArgs()
+
+	c := New("yaml")
+	c.SearchFiles = []SearchFile{{Scope: "app", Path: appFilePath}, {Scope: "user", Path: userFilePath}}
+	c.Add(Int("addend.a", 0, "The first addend").Exportable(true))
+	c.Add(Float("addend.b", 0, "The second addend").Exportable(true))
+	c.Add(Bool("subtract", false, "Subtract instead of add").Exportable(true))
+	c.Add(Str("name", "", "Name of the example").Exportable(true))
+	c.Add(Duration("timeout", time.Second, "How long to wait").Exportable(true))
+	c.Add(StrSlice("hosts", nil, "Allowed hosts").Exportable(true))
+	c.Add(StrMap("labels", nil, "Labels").Exportable(true))
+
+	_, err = c.BuildArgs([]string{})
+	require.Nil(t, err, "There is no error here")
+
+	assert.Equal(t, int64(4), c.Require("addend.a").Int(), "addend.a should be 4")
+	assert.Equal(t, 2.5, c.Require("addend.b").Float(), "addend.b should be 2.5")
+	assert.Equal(t, true, c.Require("subtract").Bool(), "subtract should come from the user file")
+	assert.Equal(t, "YAML config file", c.Require("name").Str(), "name should be YAML config file")
+	assert.Equal(t, 90*time.Second, c.Require("timeout").Duration(), "timeout should be 1m30s")
+	assert.Equal(t, []string{"a.example.com", "b.example.com"}, c.Require("hosts").StrSlice(), "hosts should come from the YAML sequence")
+	assert.Equal(t, map[string]string{"env": "dev", "team": "core"}, c.Require("labels").StrMap(), "labels should be merged")
+
+	_, err = c.BuildArgs([]string{`-config-write`, `-config-scope=app`, `-addend.a=5`})
+	assert.Equal(t, ErrConfigWritten, err, "BuildArgs should return ErrConfigWritten")
+
+	written := readFromTemporaryFile(t, appFilePath)
+	assert.Contains(t, string(written), "    a: 5\n", "The config file should be written as YAML")
+
+	_, err = c.BuildArgs([]string{})
+	require.Nil(t, err, "The written config should be read back without errors")
+	assert.Equal(t, int64(5), c.Require("addend.a").Int(), "addend.a should round-trip")
+	assert.Equal(t, 90*time.Second, c.Require("timeout").Duration(), "timeout should round-trip")
+
+	writeToTemporaryFile(t, []byte("addend:\n  a: four\nname: 5\n"), appFilePath)
+	_, err = c.BuildArgs([]string{})
+	require.IsType(t, MultiError{}, err, "YAML type errors should be parse errors")
+	assert.Equal(t, 2, err.(MultiError).Len(), "There should be 2 parse errors")
+
+	writeToTemporaryFile(t, []byte("addend:\n  b: .nan\n"), appFilePath)
+	_, err = c.BuildArgs([]string{})
+	var parseErr ParseError
+	require.True(t, errors.As(err, &parseErr), "A NaN in a YAML file should be a ParseError")
+	assert.Equal(t, "addend.b", parseErr.Key)
+	assert.True(t, errors.Is(err, strconv.ErrSyntax), "The error should wrap strconv.ErrSyntax")
+
+	writeToTemporaryFile(t, []byte("addend:\n  b: -.inf\n"), appFilePath)
+	_, err = c.BuildArgs([]string{})
+	require.True(t, errors.As(err, &parseErr), "An infinite number in a YAML file should be a ParseError")
+	assert.True(t, errors.Is(err, strconv.ErrRange), "The error should wrap strconv.ErrRange")
+}
+
+func TestTOMLConfigLoad(t *testing.T) {
+	var err error
+	var appFilePath = tempAppDir + "/config.toml"
+
+	writeToTemporaryFile(t, []byte(`
+name = "TOML config file"
+released = 2016-03-01T10:30:00Z
+ports = [80, 443]
+
+[addend]
+a = 9007199254740993
+b = 2.5
+
+[labels]
+team = "core"
+`), appFilePath)
+	resetArgs()
+
+	c := New("toml")
+	c.SearchFiles = []SearchFile{{Scope: "app", Path: appFilePath}}
+	c.Add(Int("addend.a", 0, "The first addend").Exportable(true))
+	c.Add(Float("addend.b", 0, "The second addend").Exportable(true))
+	c.Add(Str("name", "", "Name of the example").Exportable(true))
+	c.Add(Time("released", time.Time{}, "When it was released").Exportable(true))
+	c.Add(IntSlice("ports", nil, "Ports to listen on").Exportable(true))
+	c.Add(StrMap("labels", nil, "Labels").Exportable(true))
+
+	_, err = c.BuildArgs([]string{})
+	require.Nil(t, err, "There is no error here")
+
+	assert.Equal(t, int64(9007199254740993), c.Require("addend.a").Int(), "addend.a shouldn't lose precision")
+	assert.Equal(t, 2.5, c.Require("addend.b").Float(), "addend.b should be 2.5")
+	assert.Equal(t, "TOML config file", c.Require("name").Str(), "name should be TOML config file")
+	assert.Equal(t, time.Date(2016, 3, 1, 10, 30, 0, 0, time.UTC), c.Require("released").Time().UTC(), "released should come from the TOML datetime")
+	assert.Equal(t, []int64{80, 443}, c.Require("ports").IntSlice(), "ports should come from the TOML array")
+	assert.Equal(t, map[string]string{"team": "core"}, c.Require("labels").StrMap(), "labels should come from the TOML table")
+
+	_, err = c.BuildArgs([]string{`-config-write`, `-config-scope=app`, `-released=2017-01-02T03:04:05Z`})
+	assert.Equal(t, ErrConfigWritten, err, "BuildArgs should return ErrConfigWritten")
+
+	written := readFromTemporaryFile(t, appFilePath)
+	assert.Contains(t, string(written), "[addend]", "The config file should be written as TOML")
+
+	_, err = c.BuildArgs([]string{})
+	require.Nil(t, err, "The written config should be read back without errors")
+	assert.Equal(t, int64(9007199254740993), c.Require("addend.a").Int(), "addend.a should round-trip")
+	assert.Equal(t, time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC), c.Require("released").Time().UTC(), "released should round-trip")
+
+	writeToTemporaryFile(t, []byte("name = 5\n[addend]\na = 1.5\n"), appFilePath)
+	_, err = c.BuildArgs([]string{})
+	require.IsType(t, MultiError{}, err, "TOML type errors should be parse errors")
+	assert.Equal(t, 2, err.(MultiError).Len(), "There should be 2 parse errors")
+}
+
+type testKeyValueCodec struct{}
+
+func (testKeyValueCodec) Decode(in []byte) (map[string]interface{}, error) {
+	out := map[string]interface{}{}
+	for _, line := range strings.Split(string(in), "\n") {
+		if parts := strings.SplitN(line, ":", 2); len(parts) == 2 {
+			out[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return out, nil
+}
+
+func (testKeyValueCodec) Encode(in map[string]interface{}) ([]byte, error) {
+	buf := bytes.Buffer{}
+	for k, v := range in {
+		fmt.Fprintf(&buf, "%s: %v\n", k, v)
+	}
+	return buf.Bytes(), nil
+}
+
+func (testKeyValueCodec) Extensions() []string {
+	return []string{".kv"}
+}
+
+// testGoValuesCodec decodes every file to the same values, with Go types that Codecs aren't supposed to use.
+type testGoValuesCodec struct{}
+
+func (testGoValuesCodec) Decode(in []byte) (map[string]interface{}, error) {
+	return map[string]interface{}{"count": 5, "ports": []string{"80"}}, nil
+}
+
+func (testGoValuesCodec) Encode(in map[string]interface{}) ([]byte, error) {
+	return nil, nil
+}
+
+func (testGoValuesCodec) Extensions() []string {
+	return []string{".govalues"}
+}
+
+func TestCodecRegistry(t *testing.T) {
+	var err error
+	var kvFilePath = tempAppDir + "/config.kv"
+	var yamlFilePath = tempUserDir + "/config.conf"
+
+	RegisterCodec(testKeyValueCodec{})
+
+	writeToTemporaryFile(t, []byte("name: Key/value config file\n"), kvFilePath)
+	writeToTemporaryFile(t, []byte("name: YAML config file\nmode: subtract\n"), yamlFilePath)
+	resetArgs()
+
+	c := New("codec")
+	c.SearchFiles = []SearchFile{{Scope: "app", Path: kvFilePath}, {Scope: "user", Path: yamlFilePath, Format: "yaml"}}
+	c.Add(Str("name", "", "Name of the example").Exportable(true))
+	c.Add(Str("mode", "add", "subtract or add").Exportable(true))
+
+	_, err = c.BuildArgs([]string{})
+	require.Nil(t, err, "There is no error here")
+
+	assert.Equal(t, "Key/value config file", c.Require("name").Str(), "name should come from the registered codec")
+	assert.Equal(t, "subtract", c.Require("mode").Str(), "mode should come from the file with an explicit format")
+
+	_, err = c.BuildArgs([]string{`-config-save`, `-config-scope=user`, `-mode=add`})
+	require.Nil(t, err, "There is no error here")
+
+	written := readFromTemporaryFile(t, yamlFilePath)
+	assert.Contains(t, string(written), "mode: add\n", "The file with an explicit format should be written in that format")
+
+	_, err = codecFor("config.conf", "ini-but-unregistered")
+	assert.NotNil(t, err, "An unregistered format should be an error")
+
+	RegisterCodec(testGoValuesCodec{})
+	var goValuesFilePath = tempAppDir + "/config.govalues"
+	writeToTemporaryFile(t, []byte{}, goValuesFilePath)
+
+	g := New("codec-go-values")
+	g.SearchFiles = []SearchFile{{Scope: "app", Path: goValuesFilePath}}
+	g.Add(Int("count", 1, "How many").Required(true))
+	g.Add(IntSlice("ports", []int64{443}, "Ports to listen on"))
+
+	_, err = g.BuildArgs([]string{})
+	require.IsType(t, MultiError{}, err, "Values of unsupported types should be parse errors")
+	assert.Equal(t, 2, err.(MultiError).Len(), "There should be 2 parse errors")
+
+	var typeErr TypeError
+	require.True(t, errors.As(err, &typeErr), "A value of an unsupported type should be a TypeError")
+	assert.Equal(t, int64(1), g.Require("count").Int(), "count shouldn't be changed")
+	assert.Equal(t, DefaultSource, g.Require("count").Provenance().Source, "count shouldn't be set from the file")
+}
+
+func TestINIConfigLoad(t *testing.T) {
+	var err error
+	var appFilePath = tempAppDir + "/config.ini"
+
+	writeToTemporaryFile(t, []byte(`; top-level values come before any section
+name = "INI config file"
+subtract = true
+hosts = a.example.com, b.example.com
+
+[addend]
+a = 10
+b = 2.5
+
+[equation.addend]
+c = 3
+
+[labels]
+team = core
+env = prod
+`), appFilePath)
+	resetArgs()
+
+	c := New("ini")
+	c.SearchFiles = []SearchFile{{Scope: "app", Path: appFilePath}}
+	c.Add(Int("addend.a", 0, "The first addend").Exportable(true))
+	c.Add(Float("addend.b", 0, "The second addend").Exportable(true))
+	c.Add(Int("equation.addend.c", 0, "The third addend").Exportable(true))
+	c.Add(Bool("subtract", false, "Subtract instead of add").Exportable(true))
+	c.Add(Str("name", "", "Name of the example").Exportable(true))
+	c.Add(StrSlice("hosts", nil, "Allowed hosts").Exportable(true))
+	c.Add(StrMap("labels", nil, "Labels to attach"))
+
+	_, err = c.BuildArgs([]string{})
+	require.Nil(t, err, "There is no error here")
+
+	assert.Equal(t, map[string]string{"team": "core", "env": "prod"}, c.Require("labels").StrMap(), "labels should come from the [labels] section")
+	assert.Equal(t, 14, c.Require("labels").Provenance().Line, "labels should be from the first line of its section")
+
+	assert.Equal(t, int64(10), c.Require("addend.a").Int(), "addend.a should come from the [addend] section")
+	assert.Equal(t, 2.5, c.Require("addend.b").Float(), "addend.b should come from the [addend] section")
+	assert.Equal(t, int64(3), c.Require("equation.addend.c").Int(), "equation.addend.c should come from the nested section")
+	assert.Equal(t, true, c.Require("subtract").Bool(), "subtract should be true")
+	assert.Equal(t, "INI config file", c.Require("name").Str(), "name should be unquoted")
+	assert.Equal(t, []string{"a.example.com", "b.example.com"}, c.Require("hosts").StrSlice(), "hosts should be split")
+
+	_, err = c.BuildArgs([]string{`-config-write`, `-config-scope=app`, `-addend.a=5`})
+	assert.Equal(t, ErrConfigWritten, err, "BuildArgs should return ErrConfigWritten")
+
+	written := readFromTemporaryFile(t, appFilePath)
+	assert.Contains(t, string(written), "[addend]\na = 5\n", "The config file should be written as INI")
+
+	_, err = c.BuildArgs([]string{})
+	require.Nil(t, err, "The written config should be read back without errors")
+	assert.Equal(t, int64(5), c.Require("addend.a").Int(), "addend.a should round-trip")
+	assert.Equal(t, int64(3), c.Require("equation.addend.c").Int(), "equation.addend.c should round-trip")
+
+	writeToTemporaryFile(t, []byte("name = test\n\nsubtract = maybe\n"), appFilePath)
+	_, err = c.BuildArgs([]string{})
+	require.IsType(t, MultiError{}, err, "An invalid value should be a parse error")
+	assert.Contains(t, err.Error(), appFilePath+":3:12: ", "The error should have the line and column")
+}
+
+func TestDotenvConfigLoad(t *testing.T) {
+	var err error
+	var appFilePath = tempAppDir + "/.env"
+
+	writeToTemporaryFile(t, []byte(`# local development settings
+ADDEND_A=10
+export CONFIGTEST_ADDEND_B=2.5
+NAME="Dotenv config file"
+SUBTRACT=true # a comment
+UNRELATED=ignored
+`), appFilePath)
+	resetArgs()
+
+	c := New("dotenv")
+	c.EnvPrefix = "CONFIGTEST"
+	c.SearchFiles = []SearchFile{{Scope: "app", Path: appFilePath}}
+	c.Add(Int("addend.a", 0, "The first addend").Exportable(true))
+	c.Add(Float("addend.b", 0, "The second addend").Exportable(true))
+	c.Add(Bool("subtract", false, "Subtract instead of add").Exportable(true))
+	c.Add(Str("name", "", "Name of the example").Exportable(true))
+
+	_, err = c.BuildArgs([]string{})
+	require.Nil(t, err, "There is no error here")
+
+	assert.Equal(t, int64(10), c.Require("addend.a").Int(), "addend.a should be 10")
+	assert.Equal(t, 2.5, c.Require("addend.b").Float(), "addend.b should be set by its prefixed name")
+	assert.Equal(t, true, c.Require("subtract").Bool(), "subtract should be true")
+	assert.Equal(t, "Dotenv config file", c.Require("name").Str(), "name should be unquoted")
+
+	_, err = c.BuildArgs([]string{`-config-write`, `-config-scope=app`, `-addend.a=5`})
+	assert.Equal(t, ErrConfigWritten, err, "BuildArgs should return ErrConfigWritten")
+
+	written := readFromTemporaryFile(t, appFilePath)
+	assert.Contains(t, string(written), "ADDEND_A=5\n", "The config file should be written as dotenv")
+	assert.Contains(t, string(written), "NAME=Dotenv config file\n", "The config file should be written as dotenv")
+
+	writeToTemporaryFile(t, []byte("NAME=test\nSUBTRACT=maybe\n"), appFilePath)
+	_, err = c.BuildArgs([]string{})
+	require.IsType(t, MultiError{}, err, "An invalid value should be a parse error")
+	assert.Contains(t, err.Error(), appFilePath+":2:10: ", "The error should have the line and column")
+}
+
+func TestWatch(t *testing.T) {
+	var err error
+	var appFilePath = tempAppDir + "/watch.json"
+
+	writeToTemporaryFile(t, []byte(`{"addend": {"a": 1}, "name": "Before"}`), appFilePath)
+	resetArgs()
+
+	c := New("watch")
+	c.SearchFiles = []SearchFile{{Scope: "app", Path: appFilePath}}
+	c.WatchInterval = 10 * time.Millisecond
+	c.Add(Int("addend.a", 0, "The first addend").Exportable(true))
+	c.Add(Int("addend.b", 0, "The second addend").Exportable(true))
+	c.Add(Str("name", "", "Name of the example").Exportable(true).AddFilter(NonEmptyString()))
+
+	_, err = c.BuildArgs([]string{`-addend.b=3`})
+	require.Nil(t, err, "There is no error here")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errs := c.Watch(ctx)
+
+	// make sure the modification time changes, even on file systems with coarse timestamps
+	time.Sleep(20 * time.Millisecond)
+	writeToTemporaryFile(t, []byte(`{"addend": {"a": 2, "b": 2}, "name": "After"}`), appFilePath)
+
+	assert.Eventually(t, func() bool {
+		return c.Require("name").Str() == "After"
+	}, time.Second, 5*time.Millisecond, "name should be reloaded")
+	assert.Equal(t, int64(2), c.Require("addend.a").Int(), "addend.a should be reloaded")
+	assert.Equal(t, int64(3), c.Require("addend.b").Int(), "The flag should still override addend.b")
+
+	writeToTemporaryFile(t, []byte(`{"addend": {"a": 5}, "name": ""}`), appFilePath)
+
+	select {
+	case err = <-errs:
+		assert.NotNil(t, err, "An invalid config should be reported")
+	case <-time.After(time.Second):
+		t.Fatal("An invalid config should be reported")
+	}
+
+	assert.Equal(t, int64(2), c.Require("addend.a").Int(), "Invalid values shouldn't be applied")
+	assert.Equal(t, "After", c.Require("name").Str(), "Invalid values shouldn't be applied")
+
+	cancel()
+	for range errs {
+	}
+}
+
+func TestWatchMalformedFile(t *testing.T) {
+	var err error
+	var appFilePath = tempAppDir + "/watch-malformed.json"
+
+	writeToTemporaryFile(t, []byte(`{"addend": {"a": 7}}`), appFilePath)
+	resetArgs()
+
+	c := New("watch-malformed")
+	c.SearchFiles = []SearchFile{{Scope: "app", Path: appFilePath}}
+	c.WatchInterval = 10 * time.Millisecond
+	c.UnknownKeys = WarnUnknownKeys
+	c.Add(Int("addend.a", 0, "The first addend"))
+
+	_, err = c.BuildArgs([]string{})
+	require.Nil(t, err, "There is no error here")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errs := c.Watch(ctx)
+
+	// write the file in place, like an editor would, and leave it half-written
+	time.Sleep(20 * time.Millisecond)
+	require.Nil(t, ioutil.WriteFile(appFilePath, []byte(`{"addend": {"a": 8`), 0644))
+
+	select {
+	case err = <-errs:
+		assert.IsType(t, IOError{}, err, "A malformed file should be reported")
+	case <-time.After(time.Second):
+		t.Fatal("A malformed file should be reported")
+	}
+	assert.Equal(t, int64(7), c.Require("addend.a").Int(), "The current values should be kept")
+
+	require.Nil(t, ioutil.WriteFile(appFilePath, []byte(`{"addend": {"a": 9, "b": 1}}`), 0644))
+
+	var warning UnknownKeyError
+	assert.Eventually(t, func() bool {
+		select {
+		case err = <-errs:
+			return errors.As(err, &warning)
+		default:
+			return false
+		}
+	}, time.Second, 5*time.Millisecond, "Unknown keys should be reported")
+	assert.Equal(t, "addend.b", warning.Key)
+	assert.Equal(t, int64(9), c.Require("addend.a").Int(), "The fixed file should be applied")
+
+	cancel()
+	for range errs {
+	}
+}
+
+func TestConcurrentSet(t *testing.T) {
+	var err error
+
+	resetArgs()
+
+	c := New("concurrent")
+	c.SearchFiles = []SearchFile{}
+	a := c.Add(Int("addend.a", 0, "The first addend"))
+	name := c.Add(Str("name", "start", "Name of the example").AddFilter(NonEmptyString()))
+	hosts := c.Add(StrSlice("hosts", []string{}, "Hosts to connect to"))
+
+	_, err = c.BuildArgs([]string{})
+	require.Nil(t, err, "There is no error here")
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					_ = a.Int()
+					_ = name.Str()
+					_ = hosts.StrSlice()
+					_ = c.Require("name").HasScope("runtime")
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		require.Nil(t, c.Set("addend.a", strconv.Itoa(i)), "There is no error here")
+		require.Nil(t, c.Set("hosts", "a,b"), "There is no error here")
+		_, err = c.BuildArgs([]string{`-name=build`})
+		require.Nil(t, err, "There is no error here")
+	}
+
+	err = c.Set("name", "")
+	assert.NotNil(t, err, "An invalid value should be rejected")
+	err = c.Set("missing", "1")
+	assert.NotNil(t, err, "An unknown option should be rejected")
+
+	close(done)
+	wg.Wait()
+
+	require.Nil(t, c.Set("addend.a", "7"), "There is no error here")
+	assert.Equal(t, int64(7), a.Int(), "addend.a should be set")
+	assert.True(t, a.HasScope("runtime"), "addend.a should have the runtime scope")
+	assert.Equal(t, "build", name.Str(), "Invalid values shouldn't be applied")
+}
+
+func TestConcurrentPackageFunctions(t *testing.T) {
+	var err error
+
+	resetBaseOptionSet()
+	resetArgs()
+
+	Add(Int("zz.a", 0, "A number"))
+	Add(Str("zz.name", "start", "A name"))
+
+	_, err = BuildArgs([]string{})
+	require.Nil(t, err, "There is no error here")
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					_ = Require("zz.a").Int()
+					_, _ = Get("zz.name")
+					_, _ = Lookup[int64]("zz.a")
+					_ = DebugReport()
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		require.Nil(t, Set("zz.a", strconv.Itoa(i)), "There is no error here")
+	}
+
+	close(done)
+	wg.Wait()
+
+	assert.Equal(t, int64(49), Require("zz.a").Int(), "zz.a should be set")
+}
+
+func TestSnapshot(t *testing.T) {
+	var err error
+
+	resetArgs()
+
+	c := New("snapshot")
+	c.SearchFiles = []SearchFile{}
+	c.Add(Str("tls.cert", "", "The certificate file"))
+	c.Add(Str("tls.key", "", "The key file"))
+	c.AddRule(RequiredTogether("tls.cert", "tls.key"))
+
+	_, err = c.BuildArgs([]string{})
+	require.Nil(t, err, "There is no error here")
+
+	done := make(chan struct{})
+	var mismatched int32
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					snap := c.Snapshot()
+					if snap.Require("tls.cert").Str() != snap.Require("tls.key").Str() {
+						atomic.AddInt32(&mismatched, 1)
+					}
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 500; i++ {
+		name := strconv.Itoa(i)
+		_, err = c.BuildArgs([]string{"-tls.cert=" + name, "-tls.key=" + name})
+		require.Nil(t, err, "There is no error here")
+	}
+
+	close(done)
+	wg.Wait()
+
+	assert.Zero(t, atomic.LoadInt32(&mismatched), "A snapshot should never have a certificate and key from different builds")
+
+	snap := c.Snapshot()
+	_, err = c.BuildArgs([]string{"-tls.cert=new", "-tls.key=new"})
+	require.Nil(t, err, "There is no error here")
+	assert.Equal(t, "499", snap.Require("tls.cert").Str(), "A snapshot shouldn't change after it's taken")
+}
+
+func TestConcurrentPackageWatch(t *testing.T) {
+	var err error
+
+	resetBaseOptionSet()
+	resetArgs()
+
+	writeToTemporaryFile(t, []byte(`{"zz": {"a": 1}}`), SearchFiles[0].Path)
+
+	Add(Int("zz.a", 0, "A number"))
+	baseConfig.WatchInterval = time.Millisecond
+
+	_, err = BuildArgs([]string{})
+	require.Nil(t, err, "There is no error here")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := Watch(ctx)
+
+	for i := 0; i < 50; i++ {
+		if i%10 == 0 {
+			writeToTemporaryFile(t, []byte(fmt.Sprintf(`{"zz": {"a": %d}}`, i)), SearchFiles[0].Path)
+		}
+		Usage()
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	for range errs {
+	}
+
+	writeToTemporaryFile(t, []byte("{}"), SearchFiles[0].Path)
+}
+
+func TestConcurrentVar(t *testing.T) {
+	var err error
+
+	resetArgs()
+
+	level := testLogLevel(0)
+	c := New("concurrent-var")
+	c.SearchFiles = []SearchFile{}
+	o := c.Add(Var("log-level", &level, "The log level"))
+
+	_, err = c.BuildArgs([]string{})
+	require.Nil(t, err, "There is no error here")
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					_ = o.String()
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 500; i++ {
+		require.Nil(t, c.Set("log-level", []string{"debug", "error"}[i%2]), "There is no error here")
+	}
+
+	close(done)
+	wg.Wait()
+
+	assert.Equal(t, "error", o.String(), "log-level should be set")
+}
+
+func TestOnChange(t *testing.T) {
+	var err error
+
+	resetArgs()
+
+	c := New("changes")
+	c.SearchFiles = []SearchFile{}
+	c.Add(Int("addend.b", 0, "The second addend"))
+	c.Add(Str("name", "", "Name of the example"))
+
+	var changed []interface{}
+	c.Add(Int("addend.a", 0, "The first addend").AddFilter(func(o *Option) (bool, error) {
+		return o.Int() >= 0, nil
+	}).OnChange(func(old, new interface{}) {
+		changed = append(changed, old, new)
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := c.Options().Subscribe(ctx, "addend.")
+
+	_, err = c.BuildArgs([]string{`-addend.a=2`, `-addend.b=3`, `-name=Test`})
+	require.Nil(t, err, "There is no error here")
+
+	assert.Equal(t, []interface{}{int64(0), int64(2)}, changed, "OnChange should be called with the old and new values")
+	assert.Equal(t, Change{Name: "addend.a", Old: int64(0), New: int64(2), Scope: "flag"}, <-changes, "Changes should be sent in order")
+	assert.Equal(t, Change{Name: "addend.b", Old: int64(0), New: int64(3), Scope: "flag"}, <-changes, "Changes should be sent in order")
+
+	err = c.Set("addend.a", "-1")
+	assert.NotNil(t, err, "An invalid value should be rejected")
+
+	err = c.Set("addend.b", "4")
+	require.Nil(t, err, "There is no error here")
+	assert.Equal(t, Change{Name: "addend.b", Old: int64(3), New: int64(4), Scope: "runtime"}, <-changes, "Set should send a Change")
+
+	_, err = c.BuildArgs([]string{`-addend.a=2`})
+	require.Nil(t, err, "There is no error here")
+	assert.Equal(t, Change{Name: "addend.b", Old: int64(4), New: int64(0), Scope: "default"}, <-changes, "Going back to the default should send a Change")
+
+	select {
+	case ch := <-changes:
+		t.Errorf("Unexpected change %v", ch)
+	default:
+	}
+	assert.Equal(t, []interface{}{int64(0), int64(2)}, changed, "OnChange shouldn't be called for invalid or unchanged values")
+
+	_, err = c.BuildArgs([]string{`-addend.a=-5`})
+	require.NotNil(t, err, "An invalid value should fail the build")
+	assert.Equal(t, int64(-5), c.Require("addend.a").Int(), "A failed build keeps the values it loaded")
+	assert.Equal(t, []interface{}{int64(0), int64(2)}, changed, "OnChange shouldn't be called with invalid values")
+
+	_, err = c.BuildArgs([]string{`-addend.a=3`})
+	require.Nil(t, err, "There is no error here")
+	assert.Equal(t, Change{Name: "addend.a", Old: int64(2), New: int64(3), Scope: "flag"}, <-changes, "The old value should be the last one that was reported")
+	assert.Equal(t, []interface{}{int64(0), int64(2), int64(2), int64(3)}, changed, "OnChange should skip the invalid value")
+}
+
+func TestUnreadSubscription(t *testing.T) {
+	var err error
+
+	resetArgs()
+
+	c := New("unread-subscription")
+	c.SearchFiles = []SearchFile{}
+	o := c.Add(Int("workers", 0, "How many workers"))
+
+	_, err = c.BuildArgs([]string{})
+	require.Nil(t, err, "There is no error here")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := c.Options().Subscribe(ctx, "")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 100; i++ {
+			assert.Nil(t, c.Set("workers", strconv.Itoa(i)), "There is no error here")
+		}
+		_, err := c.BuildArgs([]string{})
+		assert.Nil(t, err, "There is no error here")
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("A subscription that isn't read shouldn't hold up Set or BuildArgs")
+	}
+
+	for i := 1; i <= 3; i++ {
+		assert.Equal(t, int64(i), (<-changes).New, "Changes should be held until they're read, in order")
+	}
+
+	cancel()
+	for range changes {
+	}
+
+	o.listeners.RLock()
+	defer o.listeners.RUnlock()
+	assert.Empty(t, o.listeners.fns, "A finished subscription shouldn't be kept")
+}
+
+func TestTypedOptions(t *testing.T) {
+	var err error
+
+	resetArgs()
+
+	c := New("typed")
+	c.SearchFiles = []SearchFile{}
+
+	a := NewInt("addend.a", 10, "The first addend")
+	a.Exportable(true)
+	c.Add(a.Option)
+
+	hosts := Typed[[]string](c.Add(StrSlice("hosts", []string{"localhost"}, "Hosts to connect to")))
+	timeout := NewDuration("timeout", time.Second, "How long to wait")
+	c.Add(timeout.Option)
+
+	_, err = c.BuildArgs([]string{`-addend.a=3`, `-hosts=a,b`})
+	require.Nil(t, err, "There is no error here")
+
+	assert.Equal(t, int64(3), a.Get(), "addend.a should be set by flag")
+	assert.Equal(t, []string{"a", "b"}, hosts.Get(), "hosts should be set by flag")
+	assert.Equal(t, time.Second, timeout.Get(), "timeout should be the default")
+
+	v, err := LookupIn[int64](c, "addend.a")
+	assert.Nil(t, err, "There is no error here")
+	assert.Equal(t, int64(3), v, "Lookup should return the value")
+
+	s, err := LookupIn[string](c, "addend.a")
+	assert.NotNil(t, err, "Looking up the wrong type should be an error")
+	assert.Equal(t, "", s, "Looking up the wrong type should return the zero value")
+	assert.Equal(t, `unexpected type: "addend.a": expected string, got int64`, err.Error(), "The error should say which types don't match")
+
+	var typeErr TypeError
+	require.True(t, errors.As(err, &typeErr), "Looking up the wrong type should be a TypeError")
+	assert.Equal(t, "addend.a", typeErr.Key)
+	assert.Equal(t, int64(3), typeErr.Value)
+
+	_, err = LookupIn[int64](c, "addend.b")
+	assert.NotNil(t, err, "Looking up a missing option should be an error")
+
+	assert.Panics(t, func() {
+		Typed[string](c.Require("addend.a"))
+	}, "Typed should panic if the Option isn't a T")
+}
+
+func TestProvenance(t *testing.T) {
+	var err error
+	var appFilePath = tempAppDir + "/provenance.json"
+	var userFilePath = tempUserDir + "/provenance.yaml"
+
+	writeToTemporaryFile(t, []byte("{\n\t\"addend\": {\n\t\t\"a\": 1\n\t},\n\t\"name\": \"\"\n}"), appFilePath)
+	writeToTemporaryFile(t, []byte("addend:\n  b: 2\n"), userFilePath)
+	resetArgs()
+
+	c := New("provenance")
+	c.SearchFiles = []SearchFile{{Scope: "user", Path: userFilePath}, {Scope: "app", Path: appFilePath}}
+	c.Add(Int("addend.a", 0, "The first addend"))
+	c.Add(Int("addend.b", 0, "The second addend"))
+	c.Add(Int("addend.c", 0, "The third addend"))
+	c.Add(Bool("subtract", false, "Subtract instead of add"))
+	c.Add(Str("name", "", "Name of the example").AddFilter(NonEmptyString()))
+
+	os.Setenv("PROVENANCE_ADDEND_C", "3")
+	defer os.Unsetenv("PROVENANCE_ADDEND_C")
+
+	_, err = c.BuildArgs([]string{`-subtract`, `-name`, `Test`})
+	require.Nil(t, err, "There is no error here")
+
+	strip := func(p Provenance) Provenance {
+		assert.False(t, p.Time.IsZero(), "The time should be set")
+		p.Time = time.Time{}
+		return p
+	}
+
+	assert.Equal(t, Provenance{Source: FileSource, Scope: "app", Path: appFilePath, Line: 3, Column: 8}, strip(c.Require("addend.a").Provenance()), "addend.a should come from the JSON file")
+	assert.Equal(t, Provenance{Source: FileSource, Scope: "user", Path: userFilePath, Line: 2, Column: 6}, strip(c.Require("addend.b").Provenance()), "addend.b should come from the YAML file")
+	assert.Equal(t, Provenance{Source: EnvSource, Scope: "env", EnvVar: "PROVENANCE_ADDEND_C"}, strip(c.Require("addend.c").Provenance()), "addend.c should come from the environment")
+	assert.Equal(t, Provenance{Source: FlagSource, Scope: "flag", Arg: 0}, strip(c.Require("subtract").Provenance()), "subtract should come from the first flag")
+	assert.Equal(t, Provenance{Source: FlagSource, Scope: "flag", Arg: 1}, strip(c.Require("name").Provenance()), "name should come from the second flag")
+	assert.Equal(t, "command-line argument 2", c.Require("name").Provenance().String(), "The flag's position should be described")
+	assert.Contains(t, c.Require("addend.a").DebugString(), "from: app config file "+appFilePath+":3:8", "The debug output should say where the value came from")
+
+	err = c.Set("addend.a", "4")
+	require.Nil(t, err, "There is no error here")
+	assert.Equal(t, RuntimeSource, c.Require("addend.a").Provenance().Source, "addend.a should be set at runtime")
+
+	_, err = c.BuildArgs([]string{})
+	require.NotNil(t, err, "name is empty in the file")
+	assert.Contains(t, err.Error(), "name (from app config file "+appFilePath+":5:10): value cannot be an empty string", "The validation error should say where the value came from")
+	assert.Equal(t, DefaultSource, c.Require("subtract").Provenance().Source, "Going back to the default should be recorded")
+}
+
+func TestDebugReport(t *testing.T) {
+	var err error
+	var appFilePath = tempAppDir + "/debug.json"
+
+	writeToTemporaryFile(t, []byte(`{"addend": {"a": 1}, "password": "hunter2"}`), appFilePath)
+	resetArgs()
+
+	out := bytes.Buffer{}
+	c := New("debug")
+	c.UsageWriter = &out
+	c.SearchFiles = []SearchFile{{Scope: "app", Path: appFilePath}}
+	c.Add(Int("addend.a", 0, "The first addend"))
+	c.Add(Str("name", "", "Name of the example").SortOrder(-1))
+	c.Add(Str("password", "", "The password").Sensitive(true))
+
+	_, err = c.BuildArgs([]string{`-addend.a=2`})
+	require.Nil(t, err, "There is no error here")
+
+	report := c.DebugReport()
+	require.Len(t, report, 3, "The report should have each option, but not the built-in ones")
+	assert.Equal(t, "name", report[0].Name, "The report should be sorted by SortOrder")
+	assert.Equal(t, DebugEntry{
+		Name:     "addend.a",
+		Type:     IntType,
+		Default:  "0",
+		Value:    "2",
+		Scope:    "flag",
+		From:     "command-line argument 1",
+		Shadowed: []string{"app"},
+	}, report[1], "addend.a should come from the flag, shadowing the file")
+	assert.Equal(t, "<redacted>", report[2].Value, "Sensitive values should be redacted")
+
+	_, err = c.BuildArgs([]string{`-config-debug`})
+	assert.Equal(t, ErrDebugPrinted, err, "BuildArgs should return ErrDebugPrinted")
+	assert.Contains(t, out.String(), "NAME", "The table should have a header")
+	assert.Regexp(t, `addend\.a\s+int64\s+0\s+1\s+app\s+app config file`, out.String(), "The table should have a row for addend.a")
+	assert.NotContains(t, out.String(), "hunter2", "Sensitive values should be redacted")
+
+	out.Reset()
+	_, err = c.BuildArgs([]string{`-config-debug=json`})
+	assert.Equal(t, ErrDebugPrinted, err, "BuildArgs should return ErrDebugPrinted")
+
+	entries := []DebugEntry{}
+	require.Nil(t, json.Unmarshal(out.Bytes(), &entries), "The output should be JSON")
+	assert.Equal(t, "addend.a", entries[1].Name, "The JSON should be sorted like the table")
+	assert.Equal(t, "app", entries[1].Scope, "addend.a should come from the file")
+
+	_, err = c.BuildArgs([]string{`-config-debug=yaml`})
+	assert.NotNil(t, err, "An unknown debug format should be an error")
+
+	out.Reset()
+	_, err = c.BuildArgs([]string{`-config-debug=true`})
+	assert.Equal(t, ErrDebugPrinted, err, "-config-debug=true should still print the table")
+	assert.Contains(t, out.String(), "NAME", "-config-debug=true should print the table")
+
+	out.Reset()
+	_, err = c.BuildArgs([]string{`-config-debug=false`})
+	assert.Nil(t, err, "-config-debug=false should build normally")
+	assert.Empty(t, out.String(), "-config-debug=false shouldn't print anything")
+}
+
+func TestUnknownKeys(t *testing.T) {
+	var err error
+	var appFilePath = tempAppDir + "/unknown.json"
+	var userFilePath = tempUserDir + "/unknown.yaml"
+
+	writeToTemporaryFile(t, []byte("{\n\t\"addend\": {\"aa\": 5},\n\t\"nmae\": \"Test\",\n\t\"removed\": true\n}"), appFilePath)
+	writeToTemporaryFile(t, []byte("leftover: 1\n"), userFilePath)
+	resetArgs()
+
+	c := New("unknown")
+	c.SearchFiles = []SearchFile{
+		{Scope: "user", Path: userFilePath, UnknownKeys: IgnoreUnknownKeys},
+		{Scope: "app", Path: appFilePath},
+	}
+	c.Add(Int("addend.a", 0, "The first addend"))
+	c.Add(Str("name", "", "Name of the example"))
+
+	res, err := c.BuildArgs([]string{})
+	require.Nil(t, err, "Unknown keys are ignored by default")
+	assert.Empty(t, res.Warnings, "Unknown keys are ignored by default")
+
+	c.UnknownKeys = WarnUnknownKeys
+	res, err = c.BuildArgs([]string{})
+	require.Nil(t, err, "Unknown keys should only be warnings")
+	require.Len(t, res.Warnings, 3, "Each unknown key should be a warning, except in the user file")
+	assert.Equal(t, appFilePath+`:2:19: unknown key "addend.aa" (did you mean addend.a?)`, res.Warnings[0].Error(), "The warning should suggest addend.a")
+	assert.Equal(t, appFilePath+`:3:10: unknown key "nmae" (did you mean name?)`, res.Warnings[1].Error(), "The warning should suggest name")
+	assert.Equal(t, appFilePath+`:4:13: unknown key "removed"`, res.Warnings[2].Error(), "There's nothing to suggest for removed")
+
+	c.UnknownKeys = StrictUnknownKeys
+	_, err = c.BuildArgs([]string{})
+	require.IsType(t, MultiError{}, err, "Unknown keys should be parse errors")
+	assert.Len(t, err.(MultiError).Errors, 3, "Each unknown key should be an error")
+	assert.Contains(t, err.Error(), "did you mean addend.a?", "The error should suggest addend.a")
+	assert.NotContains(t, err.Error(), "leftover", "The user file ignores unknown keys")
+}
+
+func TestRequiredOption(t *testing.T) {
+	var err error
+	var appFilePath = tempAppDir + "/required.json"
+
+	// a null doesn't set the token
+	writeToTemporaryFile(t, []byte(`{"name": "From file", "token": null}`), appFilePath)
+	resetArgs()
+
+	out := bytes.Buffer{}
+	c := New("required")
+	c.UsageWriter = &out
+	c.SearchFiles = []SearchFile{{Scope: "app", Path: appFilePath}}
+	c.Add(Str("name", "Default", "Name of the example").Required(true))
+	c.Add(Str("token", "", "The API token").Required(true))
+	c.Add(Int("addend.a", 0, "The first addend").Required(true))
+	c.Add(Str("label", "", "A label").AddFilter(NonEmptyString()).Validate(false))
+
+	_, err = c.BuildArgs([]string{})
+	require.NotNil(t, err, "Required options that weren't set should be an error")
+	assert.Equal(t, "Some required options weren't set:\n"+
+		"  -addend.a (or REQUIRED_ADDEND_A)\n"+
+		"  -token (or REQUIRED_TOKEN)\n"+
+		"Config files searched:\n"+
+		"  "+appFilePath+" (app)", err.Error(), "Every missing option should be listed, with the files that were searched")
+
+	_, err = c.BuildArgs([]string{`-help`})
+	assert.Equal(t, ErrHelpRequested, err, "Help should be shown even if required options are missing")
+	assert.Regexp(t, `-token\s+\(required\)`, out.String(), "Usage should show which options are required")
+
+	os.Setenv("REQUIRED_TOKEN", "secret")
+	defer os.Unsetenv("REQUIRED_TOKEN")
+
+	_, err = c.BuildArgs([]string{`-addend.a=0`})
+	require.Nil(t, err, "Setting a required option to its default value is fine")
+	assert.Equal(t, "From file", c.Require("name").Str(), "name should be set by the file")
+}
+
+func TestValidateDisabled(t *testing.T) {
+	var err error
+
+	resetArgs()
+
+	c := New("validate")
+	c.SearchFiles = []SearchFile{}
+	label := c.Add(Str("label", "", "A label").AddFilter(NonEmptyString()).Validate(false))
+
+	_, err = c.BuildArgs([]string{})
+	assert.Nil(t, err, "Filters shouldn't be checked when Validate is false")
+
+	label.Validate(true)
+	_, err = c.BuildArgs([]string{})
+	assert.NotNil(t, err, "Filters should be checked when Validate is true")
+}
+
+func TestRules(t *testing.T) {
+	var err error
+
+	resetArgs()
+
+	c := New("rules")
+	c.SearchFiles = []SearchFile{}
+	c.Add(Bool("subtract", false, "Subtract instead of add"))
+	c.Add(Bool("multiply", false, "Multiply instead of add"))
+	c.Add(Str("tls.cert", "", "The TLS certificate"))
+	c.Add(Str("tls.key", "", "The TLS key"))
+	c.Add(Str("tls.ca", "", "The TLS CA"))
+	c.Add(Int("min-workers", 1, "The minimum number of workers"))
+	c.Add(Int("max-workers", 4, "The maximum number of workers"))
+
+	c.AddRule(
+		MutuallyExclusive("subtract", "multiply"),
+		RequiredTogether("tls.cert", "tls.key"),
+		RequiredIf("tls.cert", "tls.ca"),
+		func(os OptionSet) error {
+			if os.Require("min-workers").Int() > os.Require("max-workers").Int() {
+				return fmt.Errorf("-min-workers can't be more than -max-workers")
+			}
+			return nil
+		},
+	)
+
+	_, err = c.BuildArgs([]string{`-subtract`, `-tls.key=key.pem`, `-tls.cert=cert.pem`})
+	require.Nil(t, err, "There is no error here")
+
+	_, err = c.BuildArgs([]string{`-subtract`, `-multiply`, `-tls.cert=cert.pem`, `-tls.ca=ca.pem`, `-min-workers=8`})
+	require.NotNil(t, err, "The rules should fail")
+	assert.Equal(t, "Some options were empty or invalid:\n"+
+		"  -subtract (from command-line argument 1) and -multiply (from command-line argument 2) can't be set together\n"+
+		"  -tls.key must be set along with -tls.cert (from command-line argument 3)\n"+
+		"  -min-workers can't be more than -max-workers", err.Error(), "Each failed rule should be in the error")
+
+	_, err = c.BuildArgs([]string{`-tls.ca=ca.pem`})
+	require.NotNil(t, err, "The rules should fail")
+	assert.Contains(t, err.Error(), "-tls.cert is required when -tls.ca (from command-line argument 1) is set", "RequiredIf should fail")
+
+	_, err = c.BuildArgs([]string{`-subtract=false`, `-multiply`})
+	require.NotNil(t, err, "An Option set to its default value still counts as set")
+	assert.Contains(t, err.Error(), "-subtract (from command-line argument 1) and -multiply (from command-line argument 2) can't be set together", "MutuallyExclusive should fail")
+
+	_, err = c.BuildArgs([]string{})
+	require.Nil(t, err, "There is no error here")
+
+	err = c.Set("multiply", "true")
+	require.Nil(t, err, "There is no error here")
+	err = c.Set("subtract", "true")
+	assert.NotNil(t, err, "Set should check the rules")
+	assert.False(t, c.Require("subtract").Bool(), "Values that break a rule shouldn't be set")
+
+	d := New("rules-defaults")
+	d.SearchFiles = []SearchFile{}
+	d.Add(Int("port", 8080, "The port to listen on"))
+	d.Add(Str("socket", "/tmp/rules.sock", "The socket to listen on"))
+	d.AddRule(MutuallyExclusive("port", "socket"))
+
+	_, err = d.BuildArgs([]string{})
+	require.Nil(t, err, "Default values don't count as set")
+
+	_, err = d.BuildArgs([]string{`-port=9000`})
+	require.Nil(t, err, "Only one of the Options is set")
+
+	_, err = d.BuildArgs([]string{`-port=9000`, `-socket=/tmp/other.sock`})
+	require.NotNil(t, err, "Both of the Options are set")
+	assert.Contains(t, err.Error(), "-port (from command-line argument 1) and -socket (from command-line argument 2) can't be set together", "MutuallyExclusive should fail")
+
+	c.AddRule(MutuallyExclusive("subtract", "divide"))
+	err = c.Set("multiply", "true")
+	assert.EqualError(t, err, "Some options were empty or invalid:\n  rule refers to an unknown option divide", "Rules should check that their options exist")
+}
+
+func TestFilters(t *testing.T) {
+	var filePath = tempAppDir + "/filters.txt"
+	writeToTemporaryFile(t, []byte("test"), filePath)
+
+	check := func(o *Option, f OptionFilterFunc) error {
+		ok, err := f(o)
+		assert.Equal(t, err == nil, ok, "Filters should only return an error when they fail")
+		return err
+	}
+
+	assert.Nil(t, check(Str("name", "abc", ""), NonEmptyString()), "abc isn't empty")
+	assert.EqualError(t, check(Str("name", "", ""), NonEmptyString()), "value cannot be an empty string")
+	assert.EqualError(t, check(StrSlice("names", []string{}, ""), NonEmptyString()), "value cannot be an empty list")
+	assert.EqualError(t, check(Int("workers", 0, ""), NonEmptyString()), "expected a string option, got int64")
+	assert.Nil(t, check(StrSlice("modes", []string{"add", "subtract"}, ""), IsOneOfStrings([]string{"add", "subtract"})), "Both are possible values")
+	assert.EqualError(t, check(StrSlice("modes", []string{"add", "multiply"}, ""), IsOneOfStrings([]string{"add", "subtract"})), "multiply is not a possible value (try one of add, subtract)")
+	assert.EqualError(t, check(Int("mode", 1, ""), IsOneOfStrings([]string{"1"})), "expected a string option, got int64")
+
+	assert.Nil(t, check(Int("workers", 4, ""), IntRange(1, 8)), "4 is in range")
+	assert.EqualError(t, check(Int("workers", 9, ""), IntRange(1, 8)), "9 is out of range (expected 1 to 8)")
+	assert.EqualError(t, check(IntSlice("workers", []int64{1, 0}, ""), IntRange(1, 8)), "0 is out of range (expected 1 to 8)")
+	assert.EqualError(t, check(Str("workers", "4", ""), IntRange(1, 8)), "expected an integer option, got string")
+
+	assert.Nil(t, check(Int("ratio", 1, ""), FloatRange(0, 1)), "Integers can be checked as floats")
+	assert.EqualError(t, check(Float("ratio", 1.5, ""), FloatRange(0, 1)), "1.5 is out of range (expected 0 to 1)")
+	assert.EqualError(t, check(Float("ratio", math.NaN(), ""), FloatRange(0, 1)), "NaN is out of range (expected 0 to 1)")
+
+	assert.Nil(t, check(Str("name", "abc-123", ""), MatchesRegexp(`^[a-z]+-\d+$`)), "abc-123 matches")
+	assert.EqualError(t, check(StrSlice("names", []string{"abc-123", "abc"}, ""), MatchesRegexp(`^[a-z]+-\d+$`)), `"abc" doesn't match ^[a-z]+-\d+$`)
+
+	assert.Nil(t, check(Str("url", "https://example.com/x", ""), IsURL("http", "https")), "https://example.com/x is a URL")
+	assert.Nil(t, check(Str("url", "", ""), IsURL()), "Empty strings pass")
+	assert.EqualError(t, check(Str("url", "example.com", ""), IsURL()), `"example.com" isn't a URL`)
+	assert.EqualError(t, check(Str("url", "ftp://example.com", ""), IsURL("http", "https")), `"ftp://example.com" isn't a URL (expected one of http, https)`)
+
+	assert.Nil(t, check(StrSlice("hosts", []string{"localhost:80", "[::1]:8080", ":9000"}, ""), IsHostPort()), "These are all host:port addresses")
+	assert.EqualError(t, check(Str("host", "localhost", ""), IsHostPort()), `"localhost" isn't a host:port address`)
+	assert.EqualError(t, check(Str("host", "localhost:99999", ""), IsHostPort()), `"localhost:99999" isn't a host:port address`)
+
+	assert.Nil(t, check(Int("port", 8080, ""), IsPort()), "8080 is a port")
+	assert.Nil(t, check(Str("port", "8080", ""), IsPort()), "8080 is a port")
+	assert.EqualError(t, check(Int("port", 0, ""), IsPort()), "0 isn't a port (expected 1 to 65535)")
+	assert.EqualError(t, check(Str("port", "http", ""), IsPort()), "http isn't a port (expected 1 to 65535)")
+
+	assert.Nil(t, check(Str("ip", "::1", ""), IsIP()), "::1 is an IP address")
+	assert.EqualError(t, check(Str("ip", "10.0.0.256", ""), IsIP()), `"10.0.0.256" isn't an IP address`)
+	assert.Nil(t, check(Str("cidr", "10.0.0.0/8", ""), IsCIDR()), "10.0.0.0/8 is a network")
+	assert.EqualError(t, check(Str("cidr", "10.0.0.0", ""), IsCIDR()), `"10.0.0.0" isn't a CIDR network (like 10.0.0.0/8)`)
+
+	assert.Nil(t, check(Str("email", "someone@example.com", ""), IsEmail()), "someone@example.com is an email address")
+	assert.EqualError(t, check(Str("email", "Someone <someone@example.com>", ""), IsEmail()), `"Someone <someone@example.com>" isn't an email address`)
+	assert.EqualError(t, check(Bool("email", true, ""), IsEmail()), "expected a string option, got bool")
+
+	assert.Nil(t, check(Str("file", filePath, ""), FileExists()), "The file exists")
+	assert.EqualError(t, check(Str("file", tempAppDir, ""), FileExists()), tempAppDir+" is a directory, not a file")
+	assert.EqualError(t, check(Str("file", filePath+".missing", ""), FileExists()), filePath+".missing doesn't exist")
+	assert.Nil(t, check(Str("dir", tempAppDir, ""), DirExists()), "The directory exists")
+	assert.EqualError(t, check(Str("dir", filePath, ""), DirExists()), filePath+" isn't a directory")
+	assert.Nil(t, check(Str("dir", tempAppDir, ""), DirWritable()), "The directory is writable")
+
+	assert.Nil(t, check(Str("password", "héllo", ""), MinLength(5)), "Length is counted in characters")
+	assert.EqualError(t, check(Str("password", "abc", ""), MinLength(5)), "too short (expected at least 5 characters, got 3)")
+	assert.EqualError(t, check(StrSlice("hosts", []string{"a", "b", "c"}, ""), MaxLength(2)), "too long (expected at most 2 items, got 3)")
+	assert.EqualError(t, check(Int("count", 3, ""), MaxLength(2)), "expected a string, list or map option, got int64")
+}
+
+func TestErrorTypes(t *testing.T) {
+	var err error
+	var appFilePath = tempAppDir + "/errors.json"
+
+	writeToTemporaryFile(t, []byte("{\n\t\"name\": 5,\n\t\"timeout\": \"soon\",\n\t\"nmae\": \"Test\"\n}"), appFilePath)
+	resetArgs()
+
+	c := New("errors")
+	c.SearchFiles = []SearchFile{
+		{Scope: "app", Path: appFilePath},
+	}
+	c.UnknownKeys = StrictUnknownKeys
+	c.Add(Str("name", "", "Name of the example"))
+	c.Add(Duration("timeout", time.Second, "How long to wait"))
+	c.Add(Int("port", 8080, "Port to listen on").AddFilter(IsPort()))
+
+	_, err = c.BuildArgs([]string{})
+	require.Error(t, err, "The file should have parse errors")
+
+	var multi MultiError
+	require.True(t, errors.As(err, &multi), "The error should be a MultiError")
+	assert.Equal(t, 3, multi.Len(), "There should be 3 errors")
+
+	var typeErr TypeError
+	require.True(t, errors.As(err, &typeErr), "A number for a string option should be a TypeError")
+	assert.Equal(t, "name", typeErr.Key)
+	assert.EqualValues(t, StringType, typeErr.Expected)
+	assert.Equal(t, "int64", typeErr.Actual)
+	assert.Equal(t, Provenance{Source: FileSource, Scope: "app", Path: appFilePath, Line: 2, Column: 10}, typeErr.From)
+
+	var parseErr ParseError
+	require.True(t, errors.As(err, &parseErr), "An invalid duration should be a ParseError")
+	assert.Equal(t, "timeout", parseErr.Key)
+	assert.Equal(t, "soon", parseErr.Value)
+	assert.Equal(t, 3, parseErr.From.Line)
+	assert.NotNil(t, errors.Unwrap(parseErr), "A ParseError should wrap the reason")
+
+	var unknownErr UnknownKeyError
+	require.True(t, errors.As(err, &unknownErr), "An unknown key should be an UnknownKeyError")
+	assert.Equal(t, "nmae", unknownErr.Key)
+	assert.Equal(t, "name", unknownErr.Suggestion)
+
+	writeToTemporaryFile(t, []byte("{}"), appFilePath)
+	t.Setenv("ERRORS_PORT", "70000")
+
+	_, err = c.BuildArgs([]string{})
+	require.Error(t, err, "The port should be invalid")
+
+	var validationErr ValidationError
+	require.True(t, errors.As(err, &validationErr), "An invalid option should be a ValidationError")
+	assert.Equal(t, "port", validationErr.Option)
+	assert.Equal(t, EnvSource, validationErr.From.Source)
+	assert.Equal(t, "ERRORS_PORT", validationErr.From.EnvVar)
+	assert.Len(t, validationErr.Errors, 1, "The filter's error should be wrapped")
+
+	t.Setenv("ERRORS_TIMEOUT", "later")
+
+	_, err = c.BuildArgs([]string{})
+	require.True(t, errors.As(err, &parseErr), "An invalid environment variable should be a ParseError")
+	assert.Equal(t, "timeout", parseErr.Key)
+	assert.Equal(t, "ERRORS_TIMEOUT", parseErr.From.EnvVar)
+	assert.Contains(t, err.Error(), "environment variable ERRORS_TIMEOUT: ", "The error should say where the value is")
+
+	os.Unsetenv("ERRORS_PORT")
+	os.Unsetenv("ERRORS_TIMEOUT")
+
+	_, err = c.BuildArgs([]string{"-name", "Test", "-timeout=never"})
+	require.True(t, errors.As(err, &parseErr), "An invalid flag should be a ParseError")
+	assert.Equal(t, "timeout", parseErr.Key)
+	assert.Equal(t, "never", parseErr.Value)
+	assert.Equal(t, Provenance{Source: FlagSource, Scope: "flag", Arg: 2}, parseErr.From)
+	assert.Contains(t, err.Error(), "command-line argument 3: ", "The error should say where the value is")
+
+	_, err = c.BuildArgs([]string{"-config-file"})
+	var builtInErr BuiltInFlagError
+	require.True(t, errors.As(err, &builtInErr), "A built-in flag without a value should be a BuiltInFlagError")
+
+	r := New("errors-required")
+	r.Add(Str("token", "", "API token").Required(true))
+	r.Add(Str("name", "", "Name of the example"))
+
+	_, err = r.BuildArgs([]string{})
+	var requiredErr RequiredError
+	require.True(t, errors.As(err, &requiredErr), "A missing required option should be a RequiredError")
+	assert.Equal(t, []MissingOption{{Name: "token", EnvVar: "ERRORS_REQUIRED_TOKEN"}}, requiredErr.Missing)
+}
+
+func TestNumberParsing(t *testing.T) {
+	var err error
+	var appFilePath = tempAppDir + "/numbers.json"
+	var userFilePath = tempUserDir + "/numbers.env"
+
+	writeToTemporaryFile(t, []byte("{}"), appFilePath)
+	writeToTemporaryFile(t, []byte("NUMBERS_MASK=0b1010_1010\nNUMBERS_RATIO=1_000.25\n"), userFilePath)
+	resetArgs()
+
+	c := New("numbers")
+	c.SearchFiles = []SearchFile{
+		{Scope: "user", Path: userFilePath},
+		{Scope: "app", Path: appFilePath},
+	}
+	c.Add(Int("count", 0, "How many"))
+	c.Add(Int("mask", 0, "Which bits"))
+	c.Add(Float("ratio", 0, "How much"))
+	c.Add(Float("scale", 1, "How big"))
+
+	_, err = c.BuildArgs([]string{"-count=1_000_000"})
+	require.Nil(t, err, "There is no error here")
+	assert.Equal(t, int64(1000000), c.Require("count").Int(), "Underscores should be allowed in flags")
+	assert.Equal(t, int64(0xaa), c.Require("mask").Int(), "Binary literals should be allowed in dotenv files")
+	assert.Equal(t, 1000.25, c.Require("ratio").Float(), "Underscores should be allowed in floats")
+
+	_, err = c.BuildArgs([]string{"-count=0x1f", "-ratio=0o10"})
+	require.Nil(t, err, "There is no error here")
+	assert.Equal(t, int64(31), c.Require("count").Int(), "Hex literals should be allowed in flags")
+	assert.Equal(t, float64(8), c.Require("ratio").Float(), "Octal literals should be allowed for floats")
+
+	_, err = c.BuildArgs([]string{"-count=abc"})
+	require.Error(t, err, "An invalid int flag should be an error")
+	assert.Contains(t, err.Error(), "count", "The error should name the option")
+
+	_, err = c.BuildArgs([]string{"-count=9223372036854775808"})
+	require.Error(t, err, "An int flag that doesn't fit in an int64 should be an error")
+	assert.Contains(t, err.Error(), "out of range", "The error should say the value is out of range")
+
+	_, err = c.BuildArgs([]string{"-ratio=1e400"})
+	require.Error(t, err, "A float flag that doesn't fit in a float64 should be an error")
+
+	for _, in := range []string{"NaN", "Inf", "+Inf", "-infinity"} {
+		_, err = c.BuildArgs([]string{"-ratio=" + in})
+		require.Error(t, err, "%s shouldn't be a valid float flag", in)
+	}
+	_, err = c.BuildArgs([]string{"-ratio=Inf"})
+	assert.True(t, errors.Is(err, strconv.ErrRange), "An infinite flag should wrap strconv.ErrRange")
+
+	t.Setenv("NUMBERS_COUNT", "ten")
+	_, err = c.BuildArgs([]string{})
+	var parseErr ParseError
+	require.True(t, errors.As(err, &parseErr), "An invalid environment variable should be a ParseError")
+	assert.Equal(t, "count", parseErr.Key)
+	assert.True(t, errors.Is(err, strconv.ErrSyntax), "The error should wrap strconv.ErrSyntax")
+	os.Unsetenv("NUMBERS_COUNT")
+
+	t.Setenv("NUMBERS_RATIO", "NaN")
+	_, err = c.BuildArgs([]string{})
+	require.True(t, errors.As(err, &parseErr), "A NaN environment variable should be a ParseError")
+	assert.Equal(t, "ratio", parseErr.Key)
+	os.Unsetenv("NUMBERS_RATIO")
+
+	writeToTemporaryFile(t, []byte(`{"count": "0x3e8", "scale": "1_000.5"}`), appFilePath)
+	_, err = c.BuildArgs([]string{})
+	require.Nil(t, err, "There is no error here")
+	assert.Equal(t, int64(1000), c.Require("count").Int(), "Quoted hex literals should be allowed in JSON files")
+	assert.Equal(t, 1000.5, c.Require("scale").Float(), "Quoted floats with underscores should be allowed in JSON files")
+
+	writeToTemporaryFile(t, []byte(`{"count": "1_000_000"}`), appFilePath)
+	_, err = c.BuildArgs([]string{})
+	require.Nil(t, err, "There is no error here")
+	assert.Equal(t, int64(1000000), c.Require("count").Int(), "Quoted underscores should be allowed in JSON files")
+
+	writeToTemporaryFile(t, []byte(`{"count": "ten"}`), appFilePath)
+	_, err = c.BuildArgs([]string{})
+	require.True(t, errors.As(err, &parseErr), "An invalid quoted number should be a ParseError")
+	assert.Equal(t, "count", parseErr.Key)
+	assert.Equal(t, "ten", parseErr.Value)
+	assert.True(t, errors.Is(err, strconv.ErrSyntax), "The error should wrap strconv.ErrSyntax")
+
+	writeToTemporaryFile(t, []byte(`{"count": 9223372036854775807}`), appFilePath)
+	_, err = c.BuildArgs([]string{})
+	require.Nil(t, err, "The largest int64 should fit")
+	assert.Equal(t, int64(math.MaxInt64), c.Require("count").Int(), "Large integers shouldn't be rounded")
+
+	writeToTemporaryFile(t, []byte(`{"count": 9223372036854775808}`), appFilePath)
+	_, err = c.BuildArgs([]string{})
+	require.True(t, errors.As(err, &parseErr), "An int that doesn't fit should be a ParseError")
+	assert.True(t, errors.Is(err, strconv.ErrRange), "The error should wrap strconv.ErrRange")
+
+	writeToTemporaryFile(t, []byte("{\n\t\"ratio\": 1e400\n}"), appFilePath)
+	_, err = c.BuildArgs([]string{})
+	require.True(t, errors.As(err, &parseErr), "A float that doesn't fit in a float64 should be a ParseError, not skip the file")
+	assert.Equal(t, "ratio", parseErr.Key)
+	assert.Equal(t, 2, parseErr.From.Line, "The error should say where the value is")
+	assert.True(t, errors.Is(err, strconv.ErrRange), "The error should wrap strconv.ErrRange")
+}
+
+func TestSizeOption(t *testing.T) {
+	var err error
+	var appFilePath = tempAppDir + "/sizes.json"
+
+	parsed := map[string]ByteSize{
+		"1024":      1024,
+		"0":         0,
+		"100MB":     100 * MB,
+		"100 mb":    100 * MB,
+		"1.5GiB":    GiB + GiB/2,
+		"512k":      512 * KB,
+		"2Gi":       2 * GiB,
+		"1_000B":    1000,
+		"0.5KiB":    512,
+		"16EiB-":    0,
+		"1.0001KB":  0,
+		"-1MB":      0,
+		"1..5MB":    0,
+		"20EB":      0,
+		"100 bytes": 0,
+	}
+	for in, expected := range parsed {
+		v, err := parseSize(in)
+		if expected == 0 && in != "0" {
+			assert.Error(t, err, "%q shouldn't be a valid size", in)
+			continue
+		}
+		assert.Nil(t, err, "%q should be a valid size", in)
+		assert.Equal(t, expected, v, "%q should be parsed", in)
+	}
+
+	formatted := map[ByteSize]string{
+		0:                   "0B",
+		123:                 "123B",
+		KB:                  "1KB",
+		KiB:                 "1KiB",
+		1500:                "1.5KB",
+		100 * MB:            "100MB",
+		100 * MiB:           "100MiB",
+		GiB + GiB/2:         "1.5GiB",
+		123456789:           "123456789B",
+		123456789 + 1:       "123456.79KB",
+		ByteSize(1<<64 - 1): "18446744073709551615B",
+	}
+	for in, expected := range formatted {
+		assert.Equal(t, expected, in.String(), "%d bytes should be formatted", uint64(in))
+		v, err := parseSize(expected)
+		assert.Nil(t, err, "%q should be a valid size", expected)
+		assert.Equal(t, in, v, "%q should be parsed back to the same size", expected)
+	}
+
+	writeToTemporaryFile(t, []byte(`{"cache": "256MiB", "upload": 1048576}`), appFilePath)
+	resetArgs()
+
+	out := bytes.Buffer{}
+	c := New("sizes")
+	c.UsageWriter = &out
+	c.SearchFiles = []SearchFile{{Scope: "app", Path: appFilePath}}
+	c.Add(Size("cache", 64*MiB, "How much to cache").Exportable(true))
+	c.Add(Size("upload", 10*MB, "The largest upload").Exportable(true))
+	c.Add(Size("buffer", 4*KiB, "The buffer size").Exportable(true))
+
+	_, err = c.BuildArgs([]string{"-buffer=512k"})
+	require.Nil(t, err, "There is no error here")
+	assert.Equal(t, uint64(256*MiB), c.Require("cache").Bytes(), "cache should come from the string in the file")
+	assert.Equal(t, uint64(MiB), c.Require("upload").Bytes(), "upload should come from the number of bytes in the file")
+	assert.Equal(t, uint64(512000), c.Require("buffer").Bytes(), "buffer should come from the flag")
+
+	exported := c.Options().Export(false, true)
+	assert.Equal(t, "256MiB", exported["cache"], "Sizes should be exported in a readable form")
+	assert.Equal(t, "1MiB", exported["upload"], "Sizes should be exported in a readable form")
+
+	_, err = c.BuildArgs([]string{`-help`})
+	assert.Equal(t, ErrHelpRequested, err)
+	assert.Regexp(t, `-cache\s+\(default: 64MiB\)`, out.String(), "Usage should show the default in a readable form")
+
+	_, err = c.BuildArgs([]string{"-buffer=lots"})
+	require.Error(t, err, "An invalid size should be an error")
+	assert.Contains(t, err.Error(), "buffer", "The error should name the option")
+
+	writeToTemporaryFile(t, []byte(`{"upload": -5}`), appFilePath)
+	_, err = c.BuildArgs([]string{})
+	var parseErr ParseError
+	require.True(t, errors.As(err, &parseErr), "A negative size should be a ParseError")
+	assert.True(t, errors.Is(err, strconv.ErrRange), "The error should wrap strconv.ErrRange")
+
+	type appConfig struct {
+		Cache ByteSize `config:"cache" default:"1GB"`
+	}
+	cfg := appConfig{}
+	b := New("sizes-bind")
+	require.Nil(t, b.BindStruct(&cfg), "There is no error binding the struct")
+	_, err = b.BuildArgs([]string{"-cache=2GiB"})
+	require.Nil(t, err, "There is no error here")
+	assert.Equal(t, 2*GiB, cfg.Cache, "The field should be filled with the size")
+	assert.Equal(t, "1GB", b.Require("cache").DefaultValueString(), "The default should come from the tag")
+}
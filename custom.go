@@ -0,0 +1,200 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// customMu is held while custom values are read or set through their Options. They're set in place rather than
+// replaced, so this is what lets them be read through the Option while they're being built.
+var customMu sync.RWMutex
+
+// customVar is the variable a custom Option was created with, and a copy of the value it held then.
+type customVar struct {
+	variable     interface{}
+	defaultValue interface{}
+}
+
+// Var creates an Option with the parameters given of type custom. `value` must be a pointer to a type that implements
+// flag.Value or encoding.TextUnmarshaler, and is set whenever the Config is built. If it also implements
+// json.Unmarshaler, values from config files are decoded with UnmarshalJSON instead. Var panics if `value` isn't a
+// pointer, or implements neither interface.
+//
+// Each build starts from a copy of what `value` held when Var was called, sets that, and copies it into `value` once
+// the build is done. Values are copied like an assignment would copy them, rather than with Set, so a list that each
+// flag adds to starts over every time; a slice or map is copied along with its items.
+//
+// Watch and Set don't change `value`, since it can't be read safely while it's being changed; they only change the
+// Option's own copy, which is what its Value and String methods return.
+func Var(name string, value interface{}, description string) *Option {
+	switch value.(type) {
+	case flag.Value, encoding.TextUnmarshaler:
+	default:
+		panic(fmt.Sprintf("go-config: Var(%q) needs a flag.Value or encoding.TextUnmarshaler, got %T", name, value))
+	}
+
+	if reflect.ValueOf(value).Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("go-config: Var(%q) needs a pointer, got %T", name, value))
+	}
+
+	v := Option{
+		Name:        name,
+		Description: description,
+
+		// the value is modified in place, so its text at this point is what's kept as the default
+		DefaultValue: customText(value),
+		custom:       &customVar{variable: value, defaultValue: newCustom(value)},
+		state:        newOptionState(value),
+		Type:         CustomType,
+
+		Options: DefaultOptionMeta,
+	}
+
+	return &v
+}
+
+// customText returns the text form of a custom value.
+func customText(value interface{}) string {
+	customMu.RLock()
+	defer customMu.RUnlock()
+
+	switch v := value.(type) {
+	case encoding.TextMarshaler:
+		by, err := v.MarshalText()
+		if err != nil {
+			return ""
+		}
+		return string(by)
+
+	case flag.Value:
+		return v.String()
+	}
+
+	return fmt.Sprintf("%v", value)
+}
+
+// setCustomText sets a custom value from its text form.
+func setCustomText(value interface{}, text string) error {
+	customMu.Lock()
+	defer customMu.Unlock()
+
+	return setText(value, text)
+}
+
+// setText sets a custom value from its text form, with customMu held.
+func setText(value interface{}, text string) error {
+	switch v := value.(type) {
+	case flag.Value:
+		return v.Set(text)
+
+	case encoding.TextUnmarshaler:
+		return v.UnmarshalText([]byte(text))
+	}
+
+	return fmt.Errorf("%T can't be set from text", value)
+}
+
+// newCustom returns a pointer to a copy of the custom value `value` points to.
+func newCustom(value interface{}) interface{} {
+	customMu.RLock()
+	defer customMu.RUnlock()
+
+	src := reflect.ValueOf(value).Elem()
+	out := reflect.New(src.Type())
+	out.Elem().Set(copyOf(src))
+
+	return out.Interface()
+}
+
+// copyCustom sets the custom value `dst` points to to a copy of the one `src` points to, like an assignment would,
+// instead of setting it from `src`'s text. Setting a value like a list from text usually adds to it.
+func copyCustom(dst, src interface{}) {
+	customMu.Lock()
+	defer customMu.Unlock()
+
+	reflect.ValueOf(dst).Elem().Set(copyOf(reflect.ValueOf(src).Elem()))
+}
+
+// copyOf returns a copy of `v`. If `v` is a slice or a map, the copy has storage of its own, so that appending to or
+// setting keys in one doesn't change the other.
+func copyOf(v reflect.Value) reflect.Value {
+	out := reflect.New(v.Type()).Elem()
+
+	switch {
+	case v.Kind() == reflect.Slice && !v.IsNil():
+		out.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Len()))
+		reflect.Copy(out, v)
+
+	case v.Kind() == reflect.Map && !v.IsNil():
+		out.Set(reflect.MakeMapWithSize(v.Type(), v.Len()))
+		for iter := v.MapRange(); iter.Next(); {
+			out.SetMapIndex(iter.Key(), iter.Value())
+		}
+
+	default:
+		out.Set(v)
+	}
+
+	return out
+}
+
+// fillVars copies the value of each custom Option into the variable it was created with.
+func (os OptionSet) fillVars() {
+	for _, o := range os {
+		if o.Type != CustomType || o.custom == nil {
+			continue
+		}
+
+		if v := o.Value(); v != o.custom.variable {
+			copyCustom(o.custom.variable, v)
+		}
+	}
+}
+
+// setCustomJSON sets a custom value from a value decoded from a config file.
+func setCustomJSON(value interface{}, in interface{}) error {
+	customMu.Lock()
+	defer customMu.Unlock()
+
+	if u, ok := value.(json.Unmarshaler); ok {
+		by, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		return u.UnmarshalJSON(by)
+	}
+
+	if s, ok := in.(string); ok {
+		return setText(value, s)
+	}
+
+	return setText(value, formatValue(in))
+}
+
+// customExportValue returns a custom value in the form it's written to a config file.
+func customExportValue(value interface{}) interface{} {
+	if _, ok := value.(encoding.TextMarshaler); !ok {
+		if _, ok := value.(json.Marshaler); ok {
+			return value
+		}
+	}
+
+	return customText(value)
+}
+
+// isBoolFlag returns true if the Option can be set from a flag without a value, like the flag package's boolean flags.
+func isBoolFlag(o *Option) bool {
+	if o.Type == BoolType {
+		return true
+	}
+
+	if b, ok := o.Value().(interface{ IsBoolFlag() bool }); ok && o.Type == CustomType {
+		return b.IsBoolFlag()
+	}
+
+	return false
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// redacted replaces the values of Sensitive Options in debugging output.
+const redacted = "<redacted>"
+
+// DebugEntry describes an Option's value and where it came from.
+type DebugEntry struct {
+	Name    string `json:"name"`
+	Type    Type   `json:"type"`
+	Default string `json:"default"`
+	Value   string `json:"value"`
+
+	// Scope is the scope the value came from, or "default", and From describes where it came from in more detail.
+	Scope string `json:"scope"`
+	From  string `json:"from"`
+
+	// Shadowed holds the scopes that set the Option before Scope, in order. Their values were replaced, or for
+	// slice Options that append and map Options, combined with the later ones.
+	Shadowed []string `json:"shadowed"`
+}
+
+// A Report describes the value of each of a Config's Options, sorted like they are in Usage().
+type Report []DebugEntry
+
+// DebugReport returns the values of the Config's Options and where they came from, which is what -config-debug
+// prints. The values of Sensitive Options are redacted.
+func (c *Config) DebugReport() Report {
+	opts := []Option{}
+	for _, opt := range c.options {
+		if !opt.isBuiltIn {
+			opts = append(opts, *opt)
+		}
+	}
+	sort.Sort(sortedUsageOptionSlice(opts))
+
+	report := make(Report, 0, len(opts))
+	for _, opt := range opts {
+		from := opt.Provenance()
+		scopes := opt.load().scopes()
+
+		e := DebugEntry{
+			Name:     opt.Name,
+			Type:     opt.Type,
+			Default:  opt.defaultValueString(""),
+			Value:    opt.String(),
+			Scope:    string(DefaultSource),
+			From:     from.String(),
+			Shadowed: []string{},
+		}
+
+		if len(scopes) > 0 {
+			e.Scope = scopes[len(scopes)-1]
+			e.Shadowed = scopes[:len(scopes)-1]
+		}
+
+		if opt.Options.Sensitive {
+			e.Default, e.Value = redacted, redacted
+		}
+
+		report = append(report, e)
+	}
+
+	return report
+}
+
+// DebugReport returns the values of the default Config's Options and where they came from. See (*Config).DebugReport.
+func DebugReport() Report {
+	return baseConfig.DebugReport()
+}
+
+// Table returns the Report as a table with a row for each Option.
+func (r Report) Table() string {
+	buf := strings.Builder{}
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "NAME\tTYPE\tDEFAULT\tVALUE\tSCOPE\tFROM\tSHADOWED")
+	for _, e := range r {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Name,
+			e.Type,
+			orEmpty(e.Default),
+			orEmpty(e.Value),
+			e.Scope,
+			e.From,
+			orEmpty(strings.Join(e.Shadowed, ", ")),
+		)
+	}
+
+	w.Flush()
+	return buf.String()
+}
+
+// JSON returns the Report as an indented JSON array.
+func (r Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "\t")
+}
+
+// Write writes the Report to `w` in `format`, which is "table" or "json". Since -config-debug used to be a bool flag,
+// "true" (or any other spelling strconv.ParseBool accepts) is the same as "table", and "false" writes nothing.
+func (r Report) Write(w io.Writer, format string) error {
+	switch debugFormat(format) {
+	case "":
+		return nil
+
+	case "table":
+		_, err := io.WriteString(w, r.Table())
+		return err
+
+	case "json":
+		by, err := r.JSON()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", by)
+		return err
+	}
+
+	return fmt.Errorf("go-config: unknown debug format %q (expected table or json)", format)
+}
+
+// debugFormat returns the format named by -config-debug's value, which is "" if nothing should be printed. It accepts
+// the bool values it took before it had formats, so -config-debug=true still prints the table.
+func debugFormat(val string) string {
+	switch val {
+	case "1", "t", "T", "true", "TRUE", "True":
+		return "table"
+	case "0", "f", "F", "false", "FALSE", "False":
+		return ""
+	}
+
+	return val
+}
+
+// isDebugFormat is the filter for -config-debug.
+func isDebugFormat(o *Option) (bool, error) {
+	switch debugFormat(o.Str()) {
+	case "", "table", "json":
+		return true, nil
+	}
+
+	return false, fmt.Errorf("%s isn't a debug format (try table or json)", o.Str())
+}
+
+func orEmpty(s string) string {
+	if s == "" {
+		return "<empty>"
+	}
+	return s
+}
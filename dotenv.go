@@ -0,0 +1,112 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// envKeyed is implemented by Codecs whose keys are environment variable names instead of Option names.
+type envKeyed interface {
+	envKeyed()
+}
+
+// dotenvCodec reads and writes .env files. Each line is an environment variable, named the same way as the ones
+// read from the environment (with or without the prefix), so ADDEND_A=10 sets addend.a. Values are untyped.
+type dotenvCodec struct{}
+
+func (dotenvCodec) envKeyed() {}
+
+func (dotenvCodec) Decode(in []byte) (map[string]interface{}, error) {
+	out := map[string]interface{}{}
+
+	for i, raw := range strings.Split(string(in), "\n") {
+		n := i + 1
+		line := strings.TrimSpace(raw)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+
+		eq := strings.Index(line, "=")
+		if eq <= 0 {
+			return nil, fmt.Errorf("line %d: expected KEY=value, got %q", n, line)
+		}
+
+		val := strings.TrimSpace(line[eq+1:])
+		if len(val) > 0 && val[0] != '"' && val[0] != '\'' {
+			// unquoted values can have a comment after them
+			if c := strings.Index(val, " #"); c >= 0 {
+				val = strings.TrimSpace(val[:c])
+			}
+		}
+
+		out[strings.TrimSpace(line[:eq])] = RawValue{
+			Text:   unquote(val),
+			Line:   n,
+			Column: valueColumn(raw, val),
+		}
+	}
+
+	return out, nil
+}
+
+func (dotenvCodec) Encode(in map[string]interface{}) ([]byte, error) {
+	vals := map[string]string{}
+	flattenEnv(vals, "", in)
+
+	keys := make([]string, 0, len(vals))
+	for k := range vals {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf := bytes.Buffer{}
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "%s=%s\n", k, quoteIfNeeded(vals[k]))
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (dotenvCodec) Extensions() []string {
+	return []string{".env"}
+}
+
+// flattenEnv adds the values in `in` to `out`, keyed by their environment variable names.
+func flattenEnv(out map[string]string, prefix string, in map[string]interface{}) {
+	for k, v := range in {
+		if child, ok := v.(map[string]interface{}); ok {
+			flattenEnv(out, prefix+k+".", child)
+			continue
+		}
+
+		out[envKey(prefix+k)] = formatValue(v)
+	}
+}
+
+// envKeysToNames replaces the keys in `in` that are environment variable names of Options with the Options' names.
+func envKeysToNames(in map[string]interface{}, options OptionSet, prefix string) map[string]interface{} {
+	names := map[string]string{}
+	for _, o := range options {
+		if o.isBuiltIn {
+			continue
+		}
+
+		names[envKey(o.Name)] = o.Name
+		names[envName(prefix, o)] = o.Name
+	}
+
+	out := make(map[string]interface{}, len(in))
+	for k, v := range in {
+		if name, ok := names[k]; ok {
+			out[name] = v
+		} else {
+			out[k] = v
+		}
+	}
+
+	return out
+}
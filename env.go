@@ -0,0 +1,99 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// EnvPrefix is the prefix the default Config uses for environment variable names. If it's empty, the prefix is
+// derived from Name.
+var EnvPrefix string
+
+// envKey converts `s` into the form used for environment variable names: upper case, with anything that isn't a
+// letter or a digit replaced by an underscore.
+func envKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return '_'
+	}, s)
+}
+
+// envPrefix returns the prefix for the Config's environment variables: EnvPrefix if it's set, otherwise one derived
+// from the base name of the application.
+func (c *Config) envPrefix() string {
+	if c.EnvPrefix != "" {
+		return c.EnvPrefix
+	}
+
+	return envKey(filepath.Base(c.Name))
+}
+
+// EnvName returns the name of the environment variable that sets the Option `o`. Unless the Option has its own
+// variable name set with EnvVar, this is the Config's prefix followed by the Option's name, so with a prefix of
+// CONFIGTEST, addend.a is set by CONFIGTEST_ADDEND_A.
+func (c *Config) EnvName(o *Option) string {
+	return envName(c.envPrefix(), o)
+}
+
+func envName(prefix string, o *Option) string {
+	if o.Options.EnvVar != "" {
+		return o.Options.EnvVar
+	}
+
+	if prefix == "" {
+		return envKey(o.Name)
+	}
+
+	return prefix + "_" + envKey(o.Name)
+}
+
+// readEnv sets each Option in `options` that has a matching environment variable from that variable's value.
+func (c *Config) readEnv(options OptionSet) error {
+	names := make([]string, 0, len(options))
+	for k, v := range options {
+		if !v.isBuiltIn {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	errs := []error{}
+	for _, k := range names {
+		opt := options[k]
+
+		name := c.EnvName(opt)
+		val, exists := os.LookupEnv(name)
+		if !exists {
+			continue
+		}
+
+		from := Provenance{Source: EnvSource, Scope: "env", EnvVar: name}
+
+		err := opt.setFromString(val, "env")
+		if err != nil {
+			errs = append(errs, ParseError{
+				Key:      opt.Name,
+				Expected: opt.Type,
+				Value:    val,
+				From:     from,
+				Err:      err,
+			})
+			continue
+		}
+
+		opt.addProvenance(from)
+	}
+
+	if len(errs) > 0 {
+		return MultiError{Errors: errs, heading: "go-config: error(s) reading environment"}
+	}
+
+	return nil
+}
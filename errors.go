@@ -0,0 +1,200 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// TypeError is a value in a config file that's the wrong type for its Option, like a string for an int64 Option. It's
+// also returned by Lookup when an Option's value isn't the type that was asked for, without a From.
+type TypeError struct {
+	// Key is the Option's name, followed by an index for an item in a list or a key for a value in a map, like
+	// hosts[1] or labels.env.
+	Key string
+
+	// Expected is the type the value should have been, and Actual is the Go type it was decoded as.
+	Expected Type
+	Actual   string
+	Value    interface{}
+
+	// From is where the value came from, including the file's scope and path.
+	From Provenance
+}
+
+func (e TypeError) Error() string {
+	return at(e.From, fmt.Sprintf("unexpected type: %q: expected %s, got %s", e.Key, e.Expected, e.Actual))
+}
+
+// ParseError is a value that couldn't be parsed as its Option's type, like "ten" for an int64 Option.
+type ParseError struct {
+	Key      string
+	Expected Type
+	Value    interface{}
+	From     Provenance
+
+	// Err is the reason the value couldn't be parsed.
+	Err error
+}
+
+func (e ParseError) Error() string {
+	return at(e.From, fmt.Sprintf("invalid value: %q: expected %s, got %#v: %s", e.Key, e.Expected, e.Value, e.Err))
+}
+
+func (e ParseError) Unwrap() error {
+	return e.Err
+}
+
+// TruncationError is a number with a fractional part in a config file for an integer Option. The Option is still set
+// to the value's integer part.
+type TruncationError struct {
+	Key        string
+	Expected   Type
+	Value      float64
+	Difference float64
+	From       Provenance
+}
+
+func (e TruncationError) Error() string {
+	return at(e.From, fmt.Sprintf("possible truncate: %q: expected %s, got %T; difference: %e", e.Key, e.Expected, e.Value, e.Difference))
+}
+
+// UnknownKeyError is a key in a config file that isn't the name of an Option, reported when unknown keys aren't
+// ignored. Suggestion is the name of the closest Option, if one's close enough to be a typo.
+type UnknownKeyError struct {
+	Key        string
+	Suggestion string
+	From       Provenance
+}
+
+func (e UnknownKeyError) Error() string {
+	if e.Suggestion != "" {
+		return at(e.From, fmt.Sprintf("unknown key %q (did you mean %s?)", e.Key, e.Suggestion))
+	}
+	return at(e.From, fmt.Sprintf("unknown key %q", e.Key))
+}
+
+// ValidationError is an Option whose value didn't pass its Filters, or a Rule that failed.
+type ValidationError struct {
+	// Option is the name of the Option, or empty for a Rule, whose error names the Options itself.
+	Option string
+	From   Provenance
+
+	// Errors holds the errors from the Filters that failed, or the Rule's error.
+	Errors []error
+}
+
+func (e ValidationError) Error() string {
+	strs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		strs[i] = err.Error()
+	}
+
+	if e.Option == "" {
+		return strings.Join(strs, "; ")
+	}
+	if e.From.Source != DefaultSource && e.From.Source != "" {
+		return fmt.Sprintf("%s (from %s): %s", e.Option, e.From, strings.Join(strs, "; "))
+	}
+	return fmt.Sprintf("%s: %s", e.Option, strings.Join(strs, "; "))
+}
+
+func (e ValidationError) Unwrap() []error {
+	return e.Errors
+}
+
+// RequiredError lists the Required Options that weren't set by a config file, an environment variable or a flag,
+// along with the config files that were searched.
+type RequiredError struct {
+	Missing []MissingOption
+	Files   []SearchFile
+}
+
+// MissingOption is a Required Option that wasn't set, and the environment variable that could have set it.
+type MissingOption struct {
+	Name   string
+	EnvVar string
+}
+
+func (e RequiredError) Error() string {
+	str := []string{"Some required options weren't set:"}
+	for _, v := range e.Missing {
+		str = append(str, fmt.Sprintf("  -%s (or %s)", v.Name, v.EnvVar))
+	}
+
+	if len(e.Files) > 0 {
+		str = append(str, "Config files searched:")
+		for _, f := range e.Files {
+			str = append(str, fmt.Sprintf("  %s (%s)", f.ExpandedPath(), f.Scope))
+		}
+	}
+
+	return strings.Join(str, "\n")
+}
+
+// BuiltInFlagError is an error parsing the built-in flags, like -config-file without a value. Build exits with status 2
+// when it gets one.
+type BuiltInFlagError struct {
+	Err error
+}
+
+func (e BuiltInFlagError) Error() string {
+	return e.Err.Error()
+}
+
+func (e BuiltInFlagError) Unwrap() error {
+	return e.Err
+}
+
+// BindError is an Option's value that doesn't fit in the struct field it's bound to with BindStruct, like 300 in an
+// int8.
+type BindError struct {
+	// Field is the path to the field, like appConfig.Workers, followed by an index for an item in a slice.
+	Field  string
+	Option string
+	Value  interface{}
+	Type   reflect.Type
+}
+
+func (e BindError) Error() string {
+	return fmt.Sprintf("%s: value %v of %s overflows %s", e.Field, e.Value, e.Option, e.Type)
+}
+
+// MultiError holds every error found in one step of building a Config, like all of the problems in a config file or
+// all of the Options that failed validation.
+type MultiError struct {
+	Errors []error
+
+	// describes what went wrong, and comes before the list of errors
+	heading string
+}
+
+func (e MultiError) Error() string {
+	strs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		strs[i] = "  " + err.Error()
+	}
+
+	return fmt.Sprintf("%s:\n%s", e.heading, strings.Join(strs, "\n"))
+}
+
+func (e MultiError) Unwrap() []error {
+	return e.Errors
+}
+
+// Len returns the number of errors.
+func (e MultiError) Len() int {
+	return len(e.Errors)
+}
+
+// at prefixes an error message about a value with where it came from: the file and position, or the environment
+// variable or flag.
+func at(from Provenance, msg string) string {
+	switch {
+	case from.Path != "":
+		return from.location() + ": " + msg
+	case from.Source != "" && from.Source != DefaultSource:
+		return from.String() + ": " + msg
+	}
+	return msg
+}
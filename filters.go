@@ -2,33 +2,378 @@ package config
 
 import (
 	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // IsOneOfStrings returns an OptionFilterFunc that checks the Option value against a list of
-// string values and returns true if the Option value matches one of the possible values
+// string values and returns true if the Option value matches one of the possible values. Each item in a list of
+// strings is checked, and it returns false if the Option doesn't hold strings.
 func IsOneOfStrings(possibleValues []string) OptionFilterFunc {
-	return func(v *Option) (bool, error) {
-		val := v.String()
+	return eachString(false, func(val string) error {
 		for _, s := range possibleValues {
 			if val == s {
-				return true, nil
+				return nil
 			}
 		}
 
-		return false, fmt.Errorf("%s is not a possible value (try one of %s)", val, strings.Join(possibleValues, ", "))
-	}
+		return fmt.Errorf("%s is not a possible value (try one of %s)", val, strings.Join(possibleValues, ", "))
+	})
 }
 
 // NonEmptyString returns an OptionFilterFunc that returns true if the Option value is a non-empty
-// string. It will also return false if the Option is not a string.
+// string. It will also return false if the Option is not a string. For a list of strings, the list and each item in
+// it must be non-empty.
 func NonEmptyString() OptionFilterFunc {
 	return func(v *Option) (bool, error) {
-		s := v.String()
-		if s == "" {
-			return false, fmt.Errorf("value cannot be an empty string")
+		vals, err := stringValues(v)
+		if err != nil {
+			return false, err
+		}
+
+		if len(vals) == 0 {
+			return false, fmt.Errorf("value cannot be an empty list")
+		}
+
+		for _, s := range vals {
+			if s == "" {
+				return false, fmt.Errorf("value cannot be an empty string")
+			}
+		}
+
+		return true, nil
+	}
+}
+
+// stringValues returns the strings an Option holds, for filters that check strings: its value, each item in a list
+// of strings, or a custom value's text.
+func stringValues(o *Option) ([]string, error) {
+	switch o.Type {
+	case StringType:
+		return []string{o.Str()}, nil
+	case StrSliceType:
+		return o.StrSlice(), nil
+	case CustomType:
+		return []string{customText(o.Value())}, nil
+	}
+
+	return nil, fmt.Errorf("expected a string option, got %s", o.Type)
+}
+
+// numberValues returns the numbers an Option holds, for filters that check numbers: its value, or each item in a
+// list of numbers.
+func numberValues(o *Option) ([]float64, error) {
+	switch o.Type {
+	case IntType:
+		return []float64{float64(o.Int())}, nil
+	case FloatType:
+		return []float64{o.Float()}, nil
+	case SizeType:
+		return []float64{float64(o.Bytes())}, nil
+	case IntSliceType:
+		vals := []float64{}
+		for _, v := range o.IntSlice() {
+			vals = append(vals, float64(v))
+		}
+		return vals, nil
+	case FloatSliceType:
+		return o.FloatSlice(), nil
+	}
+
+	return nil, fmt.Errorf("expected a number option, got %s", o.Type)
+}
+
+// eachString returns an OptionFilterFunc that checks each of an Option's strings with `check`. Empty strings are
+// skipped if `skipEmpty` is set, so an Option that hasn't been set passes.
+func eachString(skipEmpty bool, check func(s string) error) OptionFilterFunc {
+	return func(o *Option) (bool, error) {
+		vals, err := stringValues(o)
+		if err != nil {
+			return false, err
+		}
+
+		for _, s := range vals {
+			if s == "" && skipEmpty {
+				continue
+			}
+
+			err = check(s)
+			if err != nil {
+				return false, err
+			}
+		}
+
+		return true, nil
+	}
+}
+
+// IntRange returns an OptionFilterFunc that returns true if an int64 Option's value, or each of an []int64 Option's
+// values, is between min and max (inclusive).
+func IntRange(min, max int64) OptionFilterFunc {
+	return func(o *Option) (bool, error) {
+		var vals []int64
+		switch o.Type {
+		case IntType:
+			vals = []int64{o.Int()}
+		case IntSliceType:
+			vals = o.IntSlice()
+		default:
+			return false, fmt.Errorf("expected an integer option, got %s", o.Type)
+		}
+
+		for _, v := range vals {
+			if v < min || v > max {
+				return false, fmt.Errorf("%d is out of range (expected %d to %d)", v, min, max)
+			}
+		}
+
+		return true, nil
+	}
+}
+
+// FloatRange returns an OptionFilterFunc that returns true if a number Option's value, or each of a list of numbers'
+// values, is between min and max (inclusive).
+func FloatRange(min, max float64) OptionFilterFunc {
+	return func(o *Option) (bool, error) {
+		vals, err := numberValues(o)
+		if err != nil {
+			return false, err
+		}
+
+		for _, v := range vals {
+			// NaN isn't less or greater than anything, so it has to be checked for separately
+			if math.IsNaN(v) || v < min || v > max {
+				return false, fmt.Errorf("%g is out of range (expected %g to %g)", v, min, max)
+			}
+		}
+
+		return true, nil
+	}
+}
+
+// MatchesRegexp returns an OptionFilterFunc that returns true if a string Option's value, or each of a list of
+// strings, matches the regular expression `pattern`. It panics if `pattern` isn't a valid regular expression.
+func MatchesRegexp(pattern string) OptionFilterFunc {
+	re := regexp.MustCompile(pattern)
+	return eachString(false, func(s string) error {
+		if !re.MatchString(s) {
+			return fmt.Errorf("%q doesn't match %s", s, pattern)
+		}
+		return nil
+	})
+}
+
+// IsURL returns an OptionFilterFunc that returns true if a string Option's value, or each of a list of strings, is
+// an absolute URL. If `schemes` are given, the URL has to use one of them. Empty strings pass; add NonEmptyString
+// to require a value.
+func IsURL(schemes ...string) OptionFilterFunc {
+	return eachString(true, func(s string) error {
+		u, err := url.Parse(s)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%q isn't a URL", s)
+		}
+
+		if len(schemes) == 0 {
+			return nil
 		}
+		for _, scheme := range schemes {
+			if strings.EqualFold(u.Scheme, scheme) {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("%q isn't a URL (expected one of %s)", s, strings.Join(schemes, ", "))
+	})
+}
+
+// IsHostPort returns an OptionFilterFunc that returns true if a string Option's value, or each of a list of strings,
+// is a host and port like "localhost:8080", "[::1]:8080" or ":8080". Empty strings pass; add NonEmptyString to
+// require a value.
+func IsHostPort() OptionFilterFunc {
+	return eachString(true, func(s string) error {
+		_, port, err := net.SplitHostPort(s)
+		if err != nil || checkPort(port) != nil {
+			return fmt.Errorf("%q isn't a host:port address", s)
+		}
+		return nil
+	})
+}
+
+// IsPort returns an OptionFilterFunc that returns true if an Option's value is a port number from 1 to 65535. The
+// Option can be an int64, or a string, which passes if it's empty.
+func IsPort() OptionFilterFunc {
+	check := eachString(true, checkPort)
+
+	return func(o *Option) (bool, error) {
+		switch o.Type {
+		case IntType:
+			err := checkPort(strconv.FormatInt(o.Int(), 10))
+			return err == nil, err
+		case IntSliceType:
+			for _, v := range o.IntSlice() {
+				err := checkPort(strconv.FormatInt(v, 10))
+				if err != nil {
+					return false, err
+				}
+			}
+			return true, nil
+		}
+
+		return check(o)
+	}
+}
+
+func checkPort(s string) error {
+	n, err := strconv.ParseUint(s, 10, 16)
+	if err != nil || n == 0 {
+		return fmt.Errorf("%s isn't a port (expected 1 to 65535)", s)
+	}
+	return nil
+}
+
+// IsIP returns an OptionFilterFunc that returns true if a string Option's value, or each of a list of strings, is an
+// IPv4 or IPv6 address. Empty strings pass; add NonEmptyString to require a value.
+func IsIP() OptionFilterFunc {
+	return eachString(true, func(s string) error {
+		if net.ParseIP(s) == nil {
+			return fmt.Errorf("%q isn't an IP address", s)
+		}
+		return nil
+	})
+}
+
+// IsCIDR returns an OptionFilterFunc that returns true if a string Option's value, or each of a list of strings, is
+// an IP network in CIDR notation, like "10.0.0.0/8". Empty strings pass; add NonEmptyString to require a value.
+func IsCIDR() OptionFilterFunc {
+	return eachString(true, func(s string) error {
+		_, _, err := net.ParseCIDR(s)
+		if err != nil {
+			return fmt.Errorf("%q isn't a CIDR network (like 10.0.0.0/8)", s)
+		}
+		return nil
+	})
+}
+
+// IsEmail returns an OptionFilterFunc that returns true if a string Option's value, or each of a list of strings, is
+// an email address, without a display name. Empty strings pass; add NonEmptyString to require a value.
+func IsEmail() OptionFilterFunc {
+	return eachString(true, func(s string) error {
+		addr, err := mail.ParseAddress(s)
+		if err != nil || addr.Address != s {
+			return fmt.Errorf("%q isn't an email address", s)
+		}
+		return nil
+	})
+}
+
+// FileExists returns an OptionFilterFunc that returns true if a string Option's value, or each of a list of strings,
+// is the path of a file that exists and isn't a directory. Empty strings pass; add NonEmptyString to require a value.
+func FileExists() OptionFilterFunc {
+	return eachString(true, func(s string) error {
+		fi, err := os.Stat(s)
+		if err != nil {
+			return fmt.Errorf("%s doesn't exist", s)
+		}
+		if fi.IsDir() {
+			return fmt.Errorf("%s is a directory, not a file", s)
+		}
+		return nil
+	})
+}
+
+// DirExists returns an OptionFilterFunc that returns true if a string Option's value, or each of a list of strings,
+// is the path of a directory that exists. Empty strings pass; add NonEmptyString to require a value.
+func DirExists() OptionFilterFunc {
+	return eachString(true, checkDir)
+}
+
+// DirWritable returns an OptionFilterFunc that returns true if a string Option's value, or each of a list of
+// strings, is the path of a directory that exists and that a file can be created in. Empty strings pass; add
+// NonEmptyString to require a value.
+func DirWritable() OptionFilterFunc {
+	return eachString(true, func(s string) error {
+		err := checkDir(s)
+		if err != nil {
+			return err
+		}
+
+		fp, err := ioutil.TempFile(s, ".go-config-")
+		if err != nil {
+			return fmt.Errorf("%s isn't writable", s)
+		}
+		fp.Close()
+		os.Remove(fp.Name())
+
+		return nil
+	})
+}
+
+func checkDir(s string) error {
+	fi, err := os.Stat(s)
+	if err != nil {
+		return fmt.Errorf("%s doesn't exist", s)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s isn't a directory", s)
+	}
+	return nil
+}
 
+// MinLength returns an OptionFilterFunc that returns true if a string Option's value has at least `n` characters, or
+// a list or map Option has at least `n` items.
+func MinLength(n int) OptionFilterFunc {
+	return func(o *Option) (bool, error) {
+		l, unit, err := length(o)
+		if err != nil {
+			return false, err
+		}
+
+		if l < n {
+			return false, fmt.Errorf("too short (expected at least %d %s, got %d)", n, unit, l)
+		}
+		return true, nil
+	}
+}
+
+// MaxLength returns an OptionFilterFunc that returns true if a string Option's value has at most `n` characters, or
+// a list or map Option has at most `n` items.
+func MaxLength(n int) OptionFilterFunc {
+	return func(o *Option) (bool, error) {
+		l, unit, err := length(o)
+		if err != nil {
+			return false, err
+		}
+
+		if l > n {
+			return false, fmt.Errorf("too long (expected at most %d %s, got %d)", n, unit, l)
+		}
 		return true, nil
 	}
 }
+
+// length returns the number of characters in a string Option, or the number of items in a list or map Option, and
+// what it's counting.
+func length(o *Option) (int, string, error) {
+	switch o.Type {
+	case StringType:
+		return utf8.RuneCountInString(o.Str()), "characters", nil
+	case StrSliceType:
+		return len(o.StrSlice()), "items", nil
+	case IntSliceType:
+		return len(o.IntSlice()), "items", nil
+	case FloatSliceType:
+		return len(o.FloatSlice()), "items", nil
+	case StrMapType:
+		return len(o.StrMap()), "items", nil
+	}
+
+	return 0, "", fmt.Errorf("expected a string, list or map option, got %s", o.Type)
+}
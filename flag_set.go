@@ -19,19 +19,30 @@ type errUndefinedFlag struct {
 
 // A FlagSet is a set of Flags from the command-line
 type FlagSet struct {
-	name string
+	name    string
+	options OptionSet
 
 	args     []string
 	unparsed []string
 	notset   []string
 
+	// the number of arguments the FlagSet started with, to tell where each flag was
+	total int
+
 	helpFlag bool
 }
 
-// NewFlagSet instanciates a new FlagSet with an executable named `name` and OS args `args`.
+// NewFlagSet instanciates a new FlagSet with an executable named `name` and OS args `args`, which sets Options
+// in the default Config.
 func NewFlagSet(name string, args []string) (f FlagSet) {
+	return newFlagSet(name, args, baseConfig.options)
+}
+
+func newFlagSet(name string, args []string, options OptionSet) (f FlagSet) {
 	f.name = name
+	f.options = options
 	f.unparsed = args
+	f.total = len(args)
 	return
 }
 
@@ -61,13 +72,6 @@ func (f *FlagSet) parse(builtInOnly bool) error {
 		}
 	}
 
-	// fmt.Println("args", f.args)
-	// fmt.Println("unparsed", f.unparsed)
-	// fmt.Println("notset", f.notset)
-
-	// pr, _ := json.MarshalIndent(baseOptionSet.Export(), "", "  ")
-	// fmt.Println(string(pr))
-
 	return nil
 }
 
@@ -77,6 +81,7 @@ func (f *FlagSet) parseOne(builtInOnly bool) (seen bool, err error) {
 	}
 
 	arg := f.unparsed[0]
+	from := Provenance{Source: FlagSource, Scope: "flag", Arg: f.total - len(f.unparsed)}
 	if len(arg) == 0 || arg[0] != '-' || len(arg) == 1 {
 		if len(f.unparsed) > 0 {
 			f.args = f.unparsed[0:]
@@ -133,19 +138,32 @@ func (f *FlagSet) parseOne(builtInOnly bool) (seen bool, err error) {
 
 		return true, nil
 
-	} else if option, exists := baseOptionSet[name]; exists {
+	} else if option, exists := f.options[name]; exists {
 		// valid flag, might need to find a value still
 		f.unparsed = f.unparsed[1:]
 		if hasValue {
 			// the option exists, and we have a value, so we can set it
-			err := option.SetFromFlagValue(value)
+			err := option.setFromFlag(value, from)
 			if err != nil {
-				return true, fmt.Errorf("Error setting option %s to %s: %s", name, value, err)
+				return true, flagError(option, value, from, err)
 			}
 
 		} else if option.Type == BoolType {
 			// don't need a value, and we're not allowed to use two args, so we can set the value to true normally and continue
-			option.Value = true
+			option.setValue(true)
+			option.addProvenance(from)
+			return true, nil
+		} else if isBoolFlag(option) {
+			err := option.setFromFlag("true", from)
+			if err != nil {
+				return true, flagError(option, "true", from, err)
+			}
+			return true, nil
+		} else if option.implicit != "" {
+			err := option.setFromFlag(option.implicit, from)
+			if err != nil {
+				return true, flagError(option, option.implicit, from, err)
+			}
 			return true, nil
 		} else {
 			// we need a value and don't have one yet, so we need to check the next argument
@@ -155,9 +173,9 @@ func (f *FlagSet) parseOne(builtInOnly bool) (seen bool, err error) {
 				value = f.unparsed[0]
 				f.unparsed = f.unparsed[1:]
 
-				err := option.SetFromFlagValue(value)
+				err := option.setFromFlag(value, from)
 				if err != nil {
-					return true, fmt.Errorf("Error setting option %s to %s: %s", name, value, err)
+					return true, flagError(option, value, from, err)
 				}
 			}
 
@@ -206,3 +224,14 @@ func (f FlagSet) Release() []string {
 func (e errUndefinedFlag) Error() string {
 	return fmt.Sprintf("Undefined flag: -%s", e.name)
 }
+
+// flagError describes a flag's value that couldn't be used for its Option.
+func flagError(o *Option, value string, from Provenance, err error) error {
+	return ParseError{
+		Key:      o.Name,
+		Expected: o.Type,
+		Value:    value,
+		From:     from,
+		Err:      err,
+	}
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// iniCodec reads and writes INI files. Sections become prefixes of the Options' names, so `a = 10` in the [addend]
+// section sets addend.a, and [equation.addend] is the same as nesting addend inside equation. Values are untyped.
+type iniCodec struct{}
+
+func (iniCodec) Decode(in []byte) (map[string]interface{}, error) {
+	out := map[string]interface{}{}
+	section := out
+
+	for i, raw := range strings.Split(string(in), "\n") {
+		n := i + 1
+		line := strings.TrimSpace(raw)
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if line[len(line)-1] != ']' {
+				return nil, fmt.Errorf("line %d: unterminated section header %q", n, line)
+			}
+
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if name == "" {
+				return nil, fmt.Errorf("line %d: empty section name", n)
+			}
+
+			section = out
+			for _, part := range strings.Split(name, ".") {
+				child, ok := section[part].(map[string]interface{})
+				if !ok {
+					child = map[string]interface{}{}
+					section[part] = child
+				}
+				section = child
+			}
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq <= 0 {
+			return nil, fmt.Errorf("line %d: expected key = value, got %q", n, line)
+		}
+
+		val := strings.TrimSpace(line[eq+1:])
+		section[strings.TrimSpace(line[:eq])] = RawValue{
+			Text:   unquote(val),
+			Line:   n,
+			Column: valueColumn(raw, val),
+		}
+	}
+
+	return out, nil
+}
+
+func (iniCodec) Encode(in map[string]interface{}) ([]byte, error) {
+	buf := bytes.Buffer{}
+	writeINISection(&buf, "", in)
+	return buf.Bytes(), nil
+}
+
+func (iniCodec) Extensions() []string {
+	return []string{".ini"}
+}
+
+// valueColumn returns the column where `val` starts after the = in `line`.
+func valueColumn(line string, val string) int {
+	eq := strings.Index(line, "=")
+	if val == "" {
+		return len(strings.TrimRight(line, " \t\r")) + 1
+	}
+	return eq + strings.Index(line[eq:], val) + 1
+}
+
+// writeINISection writes the values in `in`, then each of its nested maps as a section of its own.
+func writeINISection(buf *bytes.Buffer, name string, in map[string]interface{}) {
+	keys := make([]string, 0, len(in))
+	for k := range in {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	sections := []string{}
+	header := name != ""
+	for _, k := range keys {
+		if _, ok := in[k].(map[string]interface{}); ok {
+			sections = append(sections, k)
+			continue
+		}
+
+		if header {
+			if buf.Len() > 0 {
+				buf.WriteString("\n")
+			}
+			fmt.Fprintf(buf, "[%s]\n", name)
+			header = false
+		}
+		fmt.Fprintf(buf, "%s = %s\n", k, quoteIfNeeded(formatValue(in[k])))
+	}
+
+	for _, k := range sections {
+		child := k
+		if name != "" {
+			child = name + "." + k
+		}
+		writeINISection(buf, child, in[k].(map[string]interface{}))
+	}
+}
+
+// unquote removes the quotes around a value if it's wrapped in double or single quotes. Double-quoted values can use
+// Go's escape sequences.
+func unquote(val string) string {
+	if len(val) < 2 {
+		return val
+	}
+
+	switch {
+	case val[0] == '"' && val[len(val)-1] == '"':
+		if s, err := strconv.Unquote(val); err == nil {
+			return s
+		}
+		return val[1 : len(val)-1]
+
+	case val[0] == '\'' && val[len(val)-1] == '\'':
+		return val[1 : len(val)-1]
+	}
+
+	return val
+}
+
+// quoteIfNeeded wraps a value in double quotes if it wouldn't be read back the same way without them.
+func quoteIfNeeded(val string) string {
+	if val != strings.TrimSpace(val) || strings.ContainsAny(val, "\"'#;\n") {
+		return strconv.Quote(val)
+	}
+
+	return val
+}
@@ -1,7 +1,6 @@
 package config
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,16 +13,27 @@ type IO interface {
 	Scope() string
 }
 
-// FileIO implements IO and writes to the filesystem
+// FileIO implements IO and writes to the filesystem. The file is read and written with the Codec registered for its
+// format, or for its extension if no format is set; files with an unknown extension are JSON.
 type FileIO struct {
-	filename string
-	scope    string
+	filename  string
+	format    string
+	scope     string
+	options   OptionSet
+	envPrefix string
+
+	unknownKeys UnknownKeyMode
 }
 
 func (f FileIO) Write() (err error) {
-	partialExport := Require("config-partial").Bool()
+	partialExport := f.options.Require("config-partial").Bool()
+
+	codec, err := codecFor(f.filename, f.format)
+	if err != nil {
+		return fmt.Errorf("go-config: %s", err)
+	}
 
-	json, err := json.MarshalIndent(baseOptionSet.Export(false, !partialExport), "", "\t")
+	by, err := codec.Encode(f.options.Export(false, !partialExport))
 	if err != nil {
 		return fmt.Errorf("go-config: error marshaling config: %s", err)
 	}
@@ -39,8 +49,8 @@ func (f FileIO) Write() (err error) {
 	}
 	defer fp.Close()
 
-	n, err := fp.Write(json)
-	if err != nil || n < len(json) {
+	n, err := fp.Write(by)
+	if err != nil || n < len(by) {
 		return fmt.Errorf("go-config: file i/o write error: %s", err)
 	}
 
@@ -48,6 +58,15 @@ func (f FileIO) Write() (err error) {
 }
 
 func (f FileIO) Read() (err error) {
+	codec, err := codecFor(f.filename, f.format)
+	if err != nil {
+		return IOError{
+			Type: "codec",
+			Path: f.filename,
+			err:  err,
+		}
+	}
+
 	fp, err := os.Open(f.filename)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -88,9 +107,13 @@ func (f FileIO) Read() (err error) {
 	}
 
 	jmap := jsonConfigMap{
-		scope: f.scope,
+		scope:   f.scope,
+		path:    f.filename,
+		options: f.options,
+
+		unknownKeys: f.unknownKeys,
 	}
-	err = json.Unmarshal(by, &jmap)
+	jmap.config, err = codec.Decode(by)
 	if err != nil {
 		return IOError{
 			Type: "unmarshal",
@@ -99,6 +122,21 @@ func (f FileIO) Read() (err error) {
 		}
 	}
 
+	if l, ok := codec.(Locator); ok {
+		jmap.positions, err = l.Locate(by)
+		if err != nil {
+			return IOError{
+				Type: "unmarshal",
+				Path: f.filename,
+				err:  err,
+			}
+		}
+	}
+
+	if _, ok := codec.(envKeyed); ok {
+		jmap.config = envKeysToNames(jmap.config, f.options, f.envPrefix)
+	}
+
 	err = jmap.Parse()
 	if err != nil {
 		return err
@@ -1,22 +1,41 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"math"
-	"strings"
+	"sort"
+	"time"
 )
 
 type jsonConfigMap struct {
-	scope  string
-	config map[string]interface{}
-	err    error
+	scope     string
+	path      string
+	positions map[string]Position
+	options   OptionSet
+	config    map[string]interface{}
+	err       error
+
+	// how keys that aren't Options are handled, and the ones that have been found
+	unknownKeys UnknownKeyMode
+	unknown     []error
 }
 
 func (j *jsonConfigMap) UnmarshalJSON(in []byte) (err error) {
-	return json.Unmarshal(in, &j.config)
+	// decode numbers as json.Numbers so that large integers aren't rounded to the nearest float64
+	dec := json.NewDecoder(bytes.NewReader(in))
+	dec.UseNumber()
+
+	err = dec.Decode(&j.config)
+	if err != nil {
+		return err
+	}
+
+	normalizeJSON(j.config)
+	return nil
 }
 
+// Parse sets the Options from the config. If any values can't be used, it returns a MultiError with each of them.
 func (j *jsonConfigMap) Parse() error {
 	defer func() {
 		if r := recover(); r != nil {
@@ -25,136 +44,373 @@ func (j *jsonConfigMap) Parse() error {
 		}
 	}()
 
-	err := parse(j.scope, j.config, "")
-	if jerr, ok := err.(jsonConfigMapParseErrorList); ok {
-		return jerr
+	errs := j.parse(j.config, "")
+	if j.unknownKeys == StrictUnknownKeys {
+		errs = append(errs, j.unknown...)
 	}
-	return err
-}
-
-type jsonConfigMapError interface {
-	error
-}
-
-type jsonConfigMapParseError struct {
-	key      string
-	got      interface{}
-	expected Type
-}
-
-func (j jsonConfigMapParseError) Error() string {
-	return fmt.Sprintf("unexpected type: %q: expected %s, got %T", j.key, j.expected, j.got)
-}
 
-type jsonConfigMapTruncateError struct {
-	key        string
-	got        interface{}
-	expected   Type
-	difference float64
-}
-
-func (j jsonConfigMapTruncateError) Error() string {
-	return fmt.Sprintf("possible truncate: %q: expected %s, got %T; difference: %e", j.key, j.expected, j.got, j.difference)
-}
-
-type jsonConfigMapParseErrorList []jsonConfigMapError
-
-func (j *jsonConfigMapParseErrorList) Merge(inc jsonConfigMapParseErrorList) {
-	*j = append(*j, inc...)
-}
-
-func (j jsonConfigMapParseErrorList) Error() string {
-	strs := make([]string, len(j))
-	for i, err := range j {
-		strs[i] = "  " + err.Error()
+	if len(errs) > 0 {
+		sortErrors(errs)
+		return MultiError{
+			Errors:  errs,
+			heading: "error(s) parsing config file " + j.path,
+		}
 	}
 
-	return fmt.Sprintf("json parse error(s): %s", strings.Join(strs, ", "))
-}
+	if len(j.unknown) > 0 && j.unknownKeys == WarnUnknownKeys {
+		sortErrors(j.unknown)
+		return unknownKeyWarnings(j.unknown)
+	}
 
-func (j jsonConfigMapParseErrorList) Len() int {
-	return len(j)
+	return nil
 }
 
-func parse(scope string, configMap map[string]interface{}, prefix string) (err error) {
-
-	errs := make(jsonConfigMapParseErrorList, 0)
+func (j *jsonConfigMap) parse(configMap map[string]interface{}, prefix string) []error {
+	errs := []error{}
 
 	for k, v := range configMap {
-		s, exists := baseOptionSet.Get(prefix + k)
+		s, exists := j.options.Get(prefix + k)
 		if exists {
-			err := parseElem(scope, s, prefix+k, v)
+			err := parseElem(j.provenance(prefix+k, v), s, prefix+k, v)
 			if err != nil {
 				errs = append(errs, err)
 			}
 		} else {
 			switch v.(type) {
 			case map[string]interface{}:
-				cerr := parse(scope, v.(map[string]interface{}), prefix+k+".")
-				if cerr != nil {
-					if childerrs, ok := cerr.(jsonConfigMapParseErrorList); ok {
-						errs.Merge(childerrs)
-					}
-				}
+				errs = append(errs, j.parse(v.(map[string]interface{}), prefix+k+".")...)
+			default:
+				j.unknown = append(j.unknown, UnknownKeyError{
+					Key:        prefix + k,
+					Suggestion: suggest(prefix+k, j.options),
+					From:       j.provenance(prefix+k, v),
+				})
 			}
 		}
 	}
 
-	if errs.Len() > 0 {
-		return errs
+	return errs
+}
+
+// sortErrors sorts errors about values in a config file by where the values are, then by key. The config is a map,
+// so this reports them in the same order every time.
+func sortErrors(errs []error) {
+	pos := func(err error) (Provenance, string) {
+		switch e := err.(type) {
+		case TypeError:
+			return e.From, e.Key
+		case ParseError:
+			return e.From, e.Key
+		case TruncationError:
+			return e.From, e.Key
+		case UnknownKeyError:
+			return e.From, e.Key
+		}
+		return Provenance{}, ""
 	}
 
-	return nil
+	sort.SliceStable(errs, func(a, b int) bool {
+		pa, ka := pos(errs[a])
+		pb, kb := pos(errs[b])
+		if pa.Line != pb.Line {
+			return pa.Line < pb.Line
+		}
+		if pa.Column != pb.Column {
+			return pa.Column < pb.Column
+		}
+		return ka < kb
+	})
 }
 
-func parseElem(scope string, opt *Option, key string, v interface{}) error {
+// provenance returns where the value `v` of `key` came from in the file.
+func (j *jsonConfigMap) provenance(key string, v interface{}) Provenance {
+	from := Provenance{
+		Source: FileSource,
+		Scope:  j.scope,
+		Path:   j.path,
+	}
+
+	if raw, ok := v.(RawValue); ok {
+		from.Line, from.Column = raw.Line, raw.Column
+	} else if pos, ok := j.positions[key]; ok {
+		from.Line, from.Column = pos.Line, pos.Column
+	}
+
+	return from
+}
+
+func parseElem(from Provenance, opt *Option, key string, v interface{}) error {
+	// a null, like {"token": null} or `token:` in YAML, doesn't set the Option, so it keeps the value it had and
+	// doesn't count as set for Required
+	if v == nil {
+		return nil
+	}
+
+	if raw, ok := v.(RawValue); ok {
+		err := opt.setFromString(raw.Text, from.Scope)
+		if err != nil {
+			return ParseError{
+				Key:      key,
+				Value:    raw.Text,
+				Expected: opt.Type,
+				Err:      err,
+				From:     from,
+			}
+		}
+
+		opt.addProvenance(from)
+		return nil
+	}
+
+	if opt.Type == CustomType {
+		err := setCustomJSON(opt.Value(), v)
+		if err != nil {
+			return ParseError{
+				Key:      key,
+				Value:    v,
+				Expected: opt.Type,
+				Err:      err,
+				From:     from,
+			}
+		}
+
+		opt.addProvenance(from)
+		return nil
+	}
+
 	switch v.(type) {
 
+	case int64:
+		if opt.Type == IntType {
+			opt.setValue(v.(int64))
+		} else if opt.Type == FloatType {
+			opt.setValue(float64(v.(int64)))
+		} else if opt.Type == DurationType {
+			// numbers are treated as seconds
+			d, err := durationFromNumber(v)
+			if err != nil {
+				return ParseError{
+					Key:      key,
+					Value:    v,
+					Expected: opt.Type,
+					Err:      err,
+					From:     from,
+				}
+			}
+			opt.setValue(d)
+		} else if opt.Type == SizeType {
+			// numbers are treated as bytes
+			size, err := sizeFromNumber(v)
+			if err != nil {
+				return ParseError{
+					Key:      key,
+					Value:    v,
+					Expected: opt.Type,
+					Err:      err,
+					From:     from,
+				}
+			}
+			opt.setValue(size)
+		} else {
+			return TypeError{
+				Key:      key,
+				Actual:   fmt.Sprintf("%T", v),
+				Value:    v,
+				Expected: opt.Type,
+				From:     from,
+			}
+		}
 	case float64:
 		if opt.Type == FloatType {
-			opt.Value = v.(float64)
+			if err := checkFloat(v.(float64)); err != nil {
+				return ParseError{
+					Key:      key,
+					Value:    v,
+					Expected: opt.Type,
+					Err:      err,
+					From:     from,
+				}
+			}
+			opt.setValue(v.(float64))
+		} else if opt.Type == DurationType {
+			// numbers are treated as seconds
+			d, err := durationFromNumber(v)
+			if err != nil {
+				return ParseError{
+					Key:      key,
+					Value:    v,
+					Expected: opt.Type,
+					Err:      err,
+					From:     from,
+				}
+			}
+			opt.setValue(d)
 		} else if opt.Type == IntType {
-			opt.Value = int64(v.(float64))
-			rounded := math.Floor(v.(float64) + 0.5)
-			diff := math.Abs(rounded - v.(float64))
+			n, diff, err := floatToInt(v.(float64))
+			if err != nil {
+				return ParseError{
+					Key:      key,
+					Value:    v,
+					Expected: opt.Type,
+					Err:      err,
+					From:     from,
+				}
+			}
+
+			opt.setValue(n)
 			if diff > 1e-32 {
-				return jsonConfigMapTruncateError{
-					key:        key,
-					got:        v,
-					expected:   opt.Type,
-					difference: diff,
+				return TruncationError{
+					Key:        key,
+					Value:      v.(float64),
+					Expected:   opt.Type,
+					Difference: diff,
+					From:       from,
+				}
+			}
+		} else if opt.Type == SizeType {
+			// numbers are treated as bytes
+			size, err := sizeFromNumber(v)
+			if err != nil {
+				return ParseError{
+					Key:      key,
+					Value:    v,
+					Expected: opt.Type,
+					Err:      err,
+					From:     from,
 				}
 			}
+			opt.setValue(size)
 		} else {
-			return jsonConfigMapParseError{
-				key:      key,
-				got:      v,
-				expected: opt.Type,
+			return TypeError{
+				Key:      key,
+				Actual:   fmt.Sprintf("%T", v),
+				Value:    v,
+				Expected: opt.Type,
+				From:     from,
 			}
 		}
 	case bool:
 		if opt.Type == BoolType {
-			opt.Value = v.(bool)
+			opt.setValue(v.(bool))
 		} else {
-			return jsonConfigMapParseError{
-				key:      key,
-				got:      v,
-				expected: opt.Type,
+			return TypeError{
+				Key:      key,
+				Actual:   fmt.Sprintf("%T", v),
+				Value:    v,
+				Expected: opt.Type,
+				From:     from,
 			}
 		}
 	case string:
 		if opt.Type == StringType {
-			opt.Value = v.(string)
+			opt.setValue(v.(string))
+		} else if opt.Type == DurationType {
+			d, err := time.ParseDuration(v.(string))
+			if err != nil {
+				return ParseError{
+					Key:      key,
+					Value:    v,
+					Expected: opt.Type,
+					Err:      err,
+					From:     from,
+				}
+			}
+			opt.setValue(d)
+		} else if opt.Type == TimeType {
+			t, err := parseTime(v.(string))
+			if err != nil {
+				return ParseError{
+					Key:      key,
+					Value:    v,
+					Expected: opt.Type,
+					Err:      err,
+					From:     from,
+				}
+			}
+			opt.setValue(t)
+		} else if isSliceType(opt.Type) || opt.Type == StrMapType || opt.Type == SizeType ||
+			opt.Type == IntType || opt.Type == FloatType {
+			// quoted numbers are parsed like flags, so "0x3e8" and "1_000_000" work in files without number literals
+			// like those
+			err := opt.setFromString(v.(string), from.Scope)
+			if err != nil {
+				return ParseError{
+					Key:      key,
+					Value:    v,
+					Expected: opt.Type,
+					Err:      err,
+					From:     from,
+				}
+			}
+		} else {
+			return TypeError{
+				Key:      key,
+				Actual:   fmt.Sprintf("%T", v),
+				Value:    v,
+				Expected: opt.Type,
+				From:     from,
+			}
+		}
+	case time.Time:
+		if opt.Type == TimeType {
+			opt.setValue(v.(time.Time))
 		} else {
-			return jsonConfigMapParseError{
-				key:      key,
-				got:      v,
-				expected: opt.Type,
+			return TypeError{
+				Key:      key,
+				Actual:   fmt.Sprintf("%T", v),
+				Value:    v,
+				Expected: opt.Type,
+				From:     from,
 			}
 		}
+	case map[string]interface{}:
+		if opt.Type != StrMapType {
+			return TypeError{
+				Key:      key,
+				Actual:   fmt.Sprintf("%T", v),
+				Value:    v,
+				Expected: opt.Type,
+				From:     from,
+			}
+		}
+
+		from = strMapPosition(from, v.(map[string]interface{}))
+		vals, err := strMapFromJSON(key, v.(map[string]interface{}), from)
+		if err != nil {
+			return err
+		}
+		opt.mergeStrMap(vals)
+
+	case []interface{}:
+		if !isSliceType(opt.Type) {
+			return TypeError{
+				Key:      key,
+				Actual:   fmt.Sprintf("%T", v),
+				Value:    v,
+				Expected: opt.Type,
+				From:     from,
+			}
+		}
+
+		vals, err := sliceFromJSON(opt.Type, key, v.([]interface{}), from)
+		if err != nil {
+			return err
+		}
+		opt.setSlice(vals, from.Scope)
+
+	default:
+		// Codecs are expected to decode numbers as int64 and float64, and lists and maps as []interface{} and
+		// map[string]interface{}, so anything else can't be used
+		return TypeError{
+			Key:      key,
+			Actual:   fmt.Sprintf("%T", v),
+			Value:    v,
+			Expected: opt.Type,
+			From:     from,
+		}
 	}
 
-	opt.AddScope(scope)
+	opt.addProvenance(from)
 
 	return nil
 }
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseInt parses an integer the way Go source does, so it can have underscores between digits, like 1_000_000, and
+// a 0x, 0o or 0b prefix for hexadecimal, octal or binary. Values that don't fit in an int64 are errors.
+func parseInt(s string) (int64, error) {
+	v, err := strconv.ParseInt(strings.TrimSpace(s), 0, 64)
+	if err != nil {
+		return 0, numberError(s, IntType, err)
+	}
+
+	return v, nil
+}
+
+// parseFloat parses a floating point number the way Go source does, with underscores between digits allowed. Integers
+// can also be written in hexadecimal, octal or binary, like they can for parseInt. Values that are too large for a
+// float64 are errors, and so are "Inf" and "NaN", which a config file can't hold.
+func parseFloat(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil && errors.Is(err, strconv.ErrSyntax) {
+		if n, ierr := strconv.ParseInt(s, 0, 64); ierr == nil {
+			return float64(n), nil
+		}
+	}
+	if err != nil {
+		return 0, numberError(s, FloatType, err)
+	}
+	if math.IsInf(v, 0) {
+		return 0, rangeError(s, FloatType)
+	}
+	if math.IsNaN(v) {
+		return 0, numberError(s, FloatType, strconv.ErrSyntax)
+	}
+
+	return v, nil
+}
+
+// checkFloat returns an error if a number from a config file is infinite, wrapping strconv.ErrRange, or NaN, wrapping
+// strconv.ErrSyntax. Numbers that are too large for a float64 are decoded as infinite.
+func checkFloat(f float64) error {
+	if math.IsInf(f, 0) {
+		return rangeError(f, FloatType)
+	}
+	if math.IsNaN(f) {
+		return fmt.Errorf("%v isn't a valid %s: %w", f, FloatType, strconv.ErrSyntax)
+	}
+
+	return nil
+}
+
+// numberError describes why `s` couldn't be parsed as a number of type `t`. The result wraps strconv.ErrRange or
+// strconv.ErrSyntax.
+func numberError(s string, t Type, err error) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return rangeError(s, t)
+	}
+
+	return fmt.Errorf("%q isn't a valid %s: %w", s, t, strconv.ErrSyntax)
+}
+
+// floatToInt converts a number from a config file to an int64. It returns an error wrapping strconv.ErrRange if `f`
+// doesn't fit, and the difference between `f` and the result if `f` isn't a whole number.
+func floatToInt(f float64) (int64, float64, error) {
+	// float64(math.MaxInt64) rounds up to 2^63, which doesn't fit
+	if math.IsNaN(f) || f >= float64(math.MaxInt64) || f < float64(math.MinInt64) {
+		return 0, 0, rangeError(f, IntType)
+	}
+
+	return int64(f), math.Abs(math.Floor(f+0.5) - f), nil
+}
+
+// durationFromNumber converts a number from a config file, which is a number of seconds, to a time.Duration. It
+// returns an error wrapping strconv.ErrRange if the result doesn't fit.
+func durationFromNumber(v interface{}) (time.Duration, error) {
+	switch v := v.(type) {
+	case int64:
+		if v > math.MaxInt64/int64(time.Second) || v < math.MinInt64/int64(time.Second) {
+			return 0, rangeError(v, DurationType)
+		}
+		return time.Duration(v) * time.Second, nil
+
+	case float64:
+		// float64(math.MaxInt64) rounds up to 2^63, which doesn't fit
+		ns := v * float64(time.Second)
+		if math.IsNaN(ns) || ns >= float64(math.MaxInt64) || ns < float64(math.MinInt64) {
+			return 0, rangeError(v, DurationType)
+		}
+		return time.Duration(ns), nil
+	}
+
+	return 0, fmt.Errorf("expected a number, got %T", v)
+}
+
+// rangeError describes a number that doesn't fit in type `t`, and wraps strconv.ErrRange.
+func rangeError(v interface{}, t Type) error {
+	return fmt.Errorf("%v is out of range for %s: %w", v, t, strconv.ErrRange)
+}
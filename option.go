@@ -2,7 +2,11 @@ package config
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 // Type is a string representing the type of data stored by an Option
@@ -16,6 +20,23 @@ const (
 	FloatType       = "float64"
 	IntType         = "int64"
 	CustomType      = "custom"
+
+	// DurationType is a time.Duration, parsed with time.ParseDuration.
+	DurationType = "duration"
+
+	// StrSliceType, IntSliceType and FloatSliceType are lists of strings, int64s and float64s.
+	StrSliceType   = "[]string"
+	IntSliceType   = "[]int64"
+	FloatSliceType = "[]float64"
+
+	// StrMapType is a map of strings to strings.
+	StrMapType = "map[string]string"
+
+	// TimeType is a time.Time, parsed from RFC 3339 timestamps or TOML and YAML datetimes.
+	TimeType = "time"
+
+	// SizeType is a ByteSize, parsed from a number of bytes with an optional unit, like 100MB or 1.5GiB.
+	SizeType = "size"
 )
 
 // Option holds information for a configuration option
@@ -26,9 +47,6 @@ type Option struct {
 	// What the option is for. This also shows up when invoking `program --help`.
 	Description string
 
-	// Holds the actual value contained by this option
-	Value interface{}
-
 	// Holds the default value for this option
 	DefaultValue interface{}
 
@@ -38,9 +56,75 @@ type Option struct {
 	// Extra options
 	Options OptionMeta
 
+	// Holds the option's current *optionState, which is replaced instead of changed so that the value can be read
+	// while it's being set
+	state *atomic.Value
+
+	isBuiltIn bool
+
+	// the functions to call when the Option changes
+	listeners *listeners
+
+	// the variable a custom (Var) Option was created with, and a copy of its default value
+	custom *customVar
+
+	// the value used when the option's flag is given without one, like a bool flag
+	implicit string
+}
+
+// optionState holds an Option's value and where it came from. Once it's stored on an Option it's never changed.
+type optionState struct {
+	value      interface{}
+	provenance []Provenance
 	overridden bool
-	scopes     []string
-	isBuiltIn  bool
+}
+
+// scopes returns the scope of each place the value came from, in order.
+func (s *optionState) scopes() []string {
+	scopes := make([]string, len(s.provenance))
+	for i, p := range s.provenance {
+		scopes[i] = p.Scope
+	}
+	return scopes
+}
+
+func newOptionState(value interface{}) *atomic.Value {
+	v := &atomic.Value{}
+	v.Store(&optionState{value: value})
+	return v
+}
+
+// load returns the Option's current state.
+func (o Option) load() *optionState {
+	if o.state == nil {
+		return &optionState{value: o.DefaultValue}
+	}
+	return o.state.Load().(*optionState)
+}
+
+// update stores a copy of the Option's current state with the changes made by `fn`.
+func (o *Option) update(fn func(s *optionState)) {
+	s := *o.load()
+	fn(&s)
+
+	if o.state == nil {
+		o.state = &atomic.Value{}
+	}
+	o.state.Store(&s)
+}
+
+// setValue replaces the Option's value.
+func (o *Option) setValue(v interface{}) {
+	o.update(func(s *optionState) {
+		s.value = v
+	})
+}
+
+// Value returns the Option's current value. It's safe to call while the Option is being set by another goroutine,
+// e.g. while the Config is being reloaded. A custom (Var) value is a pointer to the Option's own copy of the variable,
+// or to the variable itself until the Config is first built, so it should be read with String while it's being built.
+func (o Option) Value() interface{} {
+	return o.load().value
 }
 
 // OptionMeta holds information for configuring options on Options
@@ -48,14 +132,29 @@ type OptionMeta struct {
 	// Exportable is true if the option is exportable to a config.json file
 	Exportable bool
 
-	// Validate is true if the option is required
+	// Validate is true if the option's Filters are checked when it's built
 	Validate bool
 
+	// Required is true if the option has to be set by a config file, an environment variable or a flag; its
+	// default value isn't accepted
+	Required bool
+
 	// Filters is a set of boolean functions that are tested with the given value. If Validate is true, all of these must succeed.
 	Filters []OptionFilterFunc
 
 	// SortOrder controls the sort order of Options when displayed in Usage(). Defaults to 0; ties are resolved alphabetically.
 	SortOrder int
+
+	// Append is true if values for a slice option from each scope are added to the values from the scopes before it,
+	// rather than replacing them. Repeated flags are always added together.
+	Append bool
+
+	// EnvVar is the name of the environment variable that sets the option. If it's empty, the name is derived from the
+	// option's name.
+	EnvVar string
+
+	// Sensitive is true if the option's value shouldn't be shown in debugging output, like a password.
+	Sensitive bool
 }
 
 // OptionFilterFunc is a function type that takes an *Option as a parameter. It returns true, nil if the *Option passes the filter, and false, error with a reason why if it didn't.
@@ -77,7 +176,7 @@ func Str(name string, defaultValue string, description string) *Option {
 		Description: description,
 
 		DefaultValue: defaultValue,
-		Value:        defaultValue,
+		state:        newOptionState(defaultValue),
 		Type:         StringType,
 
 		Options: DefaultOptionMeta,
@@ -93,7 +192,7 @@ func Bool(name string, defaultValue bool, description string) *Option {
 		Description: description,
 
 		DefaultValue: defaultValue,
-		Value:        defaultValue,
+		state:        newOptionState(defaultValue),
 		Type:         BoolType,
 
 		Options: DefaultOptionMeta,
@@ -109,7 +208,7 @@ func Int(name string, defaultValue int64, description string) *Option {
 		Description: description,
 
 		DefaultValue: defaultValue,
-		Value:        defaultValue,
+		state:        newOptionState(defaultValue),
 		Type:         IntType,
 
 		Options: DefaultOptionMeta,
@@ -125,7 +224,7 @@ func Float(name string, defaultValue float64, description string) *Option {
 		Description: description,
 
 		DefaultValue: defaultValue,
-		Value:        defaultValue,
+		state:        newOptionState(defaultValue),
 		Type:         FloatType,
 
 		Options: DefaultOptionMeta,
@@ -134,6 +233,119 @@ func Float(name string, defaultValue float64, description string) *Option {
 	return &v
 }
 
+// Duration creates an Option with the parameters given of type time.Duration
+func Duration(name string, defaultValue time.Duration, description string) *Option {
+	v := Option{
+		Name:        name,
+		Description: description,
+
+		DefaultValue: defaultValue,
+		state:        newOptionState(defaultValue),
+		Type:         DurationType,
+
+		Options: DefaultOptionMeta,
+	}
+
+	return &v
+}
+
+// Size creates an Option with the parameters given of type ByteSize
+func Size(name string, defaultValue ByteSize, description string) *Option {
+	v := Option{
+		Name:        name,
+		Description: description,
+
+		DefaultValue: defaultValue,
+		state:        newOptionState(defaultValue),
+		Type:         SizeType,
+
+		Options: DefaultOptionMeta,
+	}
+
+	return &v
+}
+
+// Time creates an Option with the parameters given of type time.Time
+func Time(name string, defaultValue time.Time, description string) *Option {
+	v := Option{
+		Name:        name,
+		Description: description,
+
+		DefaultValue: defaultValue,
+		state:        newOptionState(defaultValue),
+		Type:         TimeType,
+
+		Options: DefaultOptionMeta,
+	}
+
+	return &v
+}
+
+// StrSlice creates an Option with the parameters given of type []string
+func StrSlice(name string, defaultValue []string, description string) *Option {
+	v := Option{
+		Name:        name,
+		Description: description,
+
+		DefaultValue: defaultValue,
+		state:        newOptionState(defaultValue),
+		Type:         StrSliceType,
+
+		Options: DefaultOptionMeta,
+	}
+
+	return &v
+}
+
+// IntSlice creates an Option with the parameters given of type []int64
+func IntSlice(name string, defaultValue []int64, description string) *Option {
+	v := Option{
+		Name:        name,
+		Description: description,
+
+		DefaultValue: defaultValue,
+		state:        newOptionState(defaultValue),
+		Type:         IntSliceType,
+
+		Options: DefaultOptionMeta,
+	}
+
+	return &v
+}
+
+// FloatSlice creates an Option with the parameters given of type []float64
+func FloatSlice(name string, defaultValue []float64, description string) *Option {
+	v := Option{
+		Name:        name,
+		Description: description,
+
+		DefaultValue: defaultValue,
+		state:        newOptionState(defaultValue),
+		Type:         FloatSliceType,
+
+		Options: DefaultOptionMeta,
+	}
+
+	return &v
+}
+
+// StrMap creates an Option with the parameters given of type map[string]string. Values from each scope are merged
+// into the values from the scopes before it, key by key.
+func StrMap(name string, defaultValue map[string]string, description string) *Option {
+	v := Option{
+		Name:        name,
+		Description: description,
+
+		DefaultValue: defaultValue,
+		state:        newOptionState(defaultValue),
+		Type:         StrMapType,
+
+		Options: DefaultOptionMeta,
+	}
+
+	return &v
+}
+
 // Enum creates an Option with the parameters given of type string and a built-in validation to make sure
 // that the parsed Option value is contained within the possibleValues argument.
 func Enum(name string, possibleValues []string, defaultValue string, description string) *Option {
@@ -143,7 +355,7 @@ func Enum(name string, possibleValues []string, defaultValue string, description
 		Description: description,
 
 		DefaultValue: defaultValue,
-		Value:        defaultValue,
+		state:        newOptionState(defaultValue),
 		Type:         StringType,
 
 		Options: DefaultOptionMeta,
@@ -156,41 +368,96 @@ func Enum(name string, possibleValues []string, defaultValue string, description
 	return &v
 }
 
-// DebugString returns a string describing some attributes about the Option, including the name, value, type and what scopes it came from.
+// DebugString returns a string describing some attributes about the Option, including the name, value, type, what scopes it came from and
+// where its value was last set.
 func (o Option) DebugString() string {
-	return fmt.Sprintf(`name: %s, value: %v, type: %s, scopes: %s`, o.Name, o.Value, o.Type, o.scopes)
+	return fmt.Sprintf(`name: %s, value: %s, type: %s, scopes: %s, from: %s`, o.Name, o.String(), o.Type, o.load().scopes(), o.Provenance())
 }
 
 // String implements fmt.Stringer. This is used for printing the OptionSet if needed; you should use Str() to
 // return the string value of a string Option, as it'll return what you expect all the time.
 func (o Option) String() string {
-	return fmt.Sprintf(`%v`, o.Value)
+	if o.Type == CustomType {
+		return customText(o.Value())
+	}
+
+	return formatValue(o.Value())
 }
 
 // Str returns the string value of the option. Will panic if the Option's type is not a string.
 func (o Option) Str() string {
-	return o.Value.(string)
+	return o.Value().(string)
 }
 
 // Bool returns the bool value of the option. Will panic if the Option's type is not a bool.
 func (o Option) Bool() bool {
-	return o.Value.(bool)
+	return o.Value().(bool)
 }
 
 // Float returns the float64 value of the option. Will panic if the Option's type is not a float64.
 func (o Option) Float() float64 {
-	return o.Value.(float64)
+	return o.Value().(float64)
 }
 
 // Int returns the int64 value of the option. Will panic if the Option's type not an int64.
 func (o Option) Int() int64 {
-	return o.Value.(int64)
+	return o.Value().(int64)
+}
+
+// Duration returns the time.Duration value of the option. Will panic if the Option's type is not a time.Duration.
+func (o Option) Duration() time.Duration {
+	return o.Value().(time.Duration)
+}
+
+// Bytes returns the number of bytes in the ByteSize value of the option. Will panic if the Option's type is not a
+// ByteSize.
+func (o Option) Bytes() uint64 {
+	return uint64(o.Value().(ByteSize))
+}
+
+// Time returns the time.Time value of the option. Will panic if the Option's type is not a time.Time.
+func (o Option) Time() time.Time {
+	return o.Value().(time.Time)
+}
+
+// StrSlice returns the []string value of the option. Will panic if the Option's type is not a []string.
+func (o Option) StrSlice() []string {
+	return o.Value().([]string)
+}
+
+// IntSlice returns the []int64 value of the option. Will panic if the Option's type is not a []int64.
+func (o Option) IntSlice() []int64 {
+	return o.Value().([]int64)
+}
+
+// FloatSlice returns the []float64 value of the option. Will panic if the Option's type is not a []float64.
+func (o Option) FloatSlice() []float64 {
+	return o.Value().([]float64)
+}
+
+// StrMap returns the map[string]string value of the option. Will panic if the Option's type is not a map[string]string.
+func (o Option) StrMap() map[string]string {
+	return o.Value().(map[string]string)
+}
+
+// exportValue returns the Option's value in the form it's written to a config file.
+func (o Option) exportValue() interface{} {
+	switch o.Type {
+	case DurationType:
+		return o.Value().(time.Duration).String()
+	case SizeType:
+		return o.Value().(ByteSize).String()
+	case CustomType:
+		return customExportValue(o.Value())
+	}
+
+	return o.Value()
 }
 
 // defaultValueString returns the Option's default value as a string. If that value resolves to "", it'll return the
 // emptyReplacement argument instead.
 func (o Option) defaultValueString(emptyReplacement string) string {
-	ret := fmt.Sprintf(`%v`, o.DefaultValue)
+	ret := formatValue(o.DefaultValue)
 
 	if ret == "" {
 		ret = emptyReplacement
@@ -199,18 +466,75 @@ func (o Option) defaultValueString(emptyReplacement string) string {
 	return ret
 }
 
-// AddScope adds a scope to an Option indicating that it was parsed in a file with the given scope.
-func (o *Option) AddScope(s string) {
-	if o.scopes == nil {
-		o.scopes = make([]string, 0)
+// formatValue returns a value as a string for display, with slices as comma-separated lists and maps as
+// comma-separated lists of key=value pairs.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+
+	case map[string]string:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		strs := make([]string, len(keys))
+		for i, k := range keys {
+			strs[i] = k + "=" + v[k]
+		}
+		return strings.Join(strs, ",")
+
+	case []string:
+		return strings.Join(v, ",")
+
+	case []int64:
+		strs := make([]string, len(v))
+		for i, n := range v {
+			strs[i] = strconv.FormatInt(n, 10)
+		}
+		return strings.Join(strs, ",")
+
+	case []float64:
+		strs := make([]string, len(v))
+		for i, n := range v {
+			strs[i] = strconv.FormatFloat(n, 'g', -1, 64)
+		}
+		return strings.Join(strs, ",")
 	}
 
-	o.scopes = append(o.scopes, s)
+	return fmt.Sprintf("%v", v)
+}
+
+// timeLayouts are the layouts parseTime accepts, in order.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseTime parses an RFC 3339 timestamp, a date and time without a time zone (which is treated as UTC) or a date.
+func parseTime(val string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		t, err := time.Parse(layout, val)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q (expected an RFC 3339 timestamp)", val)
+}
+
+// AddScope adds a scope to an Option indicating that it was parsed in a file with the given scope.
+func (o *Option) AddScope(s string) {
+	o.addProvenance(Provenance{Source: sourceOf(s), Scope: s})
 }
 
 // HasScope returns true if the Option has the specified scope.
 func (o *Option) HasScope(s string) bool {
-	for _, v := range o.scopes {
+	for _, v := range o.load().scopes() {
 		if v == s {
 			return true
 		}
@@ -226,40 +550,95 @@ func (o Option) DefaultValueString() string {
 // SetFromFlagValue attempts to set the Option's value as its proper type by parsing the string argument, and also
 // sets a hidden value on the Option indicating it was overridden by a flag argument.
 func (o *Option) SetFromFlagValue(val string) (err error) {
-	err = o.SetFromString(val)
+	return o.setFromFlag(val, Provenance{Source: FlagSource, Scope: "flag", Arg: -1})
+}
+
+// setFromFlag sets the Option from a flag's value like SetFromFlagValue, recording `p` as where it came from.
+func (o *Option) setFromFlag(val string, p Provenance) (err error) {
+	err = o.setFromString(val, "flag")
 	if err != nil {
 		return err
 	}
 
-	o.overridden = true
-	o.AddScope("flag")
+	o.update(func(s *optionState) {
+		s.overridden = true
+	})
+	o.addProvenance(p)
 	return nil
 }
 
-// SetFromString attempts to set the Option's value as its proper type by parsing the string argument
+// SetFromString attempts to set the Option's value as its proper type by parsing the string argument. Slice Options
+// take a comma-separated list, which replaces the current value, and map Options take a comma-separated list of
+// key=value pairs, which are merged into the current value.
 func (o *Option) SetFromString(val string) (err error) {
+	return o.setFromString(val, "")
+}
+
+// setFromString is SetFromString for a value that comes from `scope`, which decides whether a slice Option's
+// values are added to its current ones.
+func (o *Option) setFromString(val string, scope string) (err error) {
 	switch o.Type {
 	case StringType:
-		o.Value = val
+		o.setValue(val)
 
 	case IntType:
-		v, err := strconv.ParseInt(val, 0, 64)
-		if err == nil {
-			o.Value = v
+		v, perr := parseInt(val)
+		if perr != nil {
+			return perr
 		}
+		o.setValue(v)
 
 	case FloatType:
-		v, err := strconv.ParseFloat(val, 64)
-		if err == nil {
-			o.Value = v
+		v, perr := parseFloat(val)
+		if perr != nil {
+			return perr
+		}
+		o.setValue(v)
+
+	case DurationType:
+		v, perr := time.ParseDuration(val)
+		if perr != nil {
+			return perr
+		}
+		o.setValue(v)
+
+	case TimeType:
+		v, perr := parseTime(val)
+		if perr != nil {
+			return perr
+		}
+		o.setValue(v)
+
+	case SizeType:
+		v, perr := parseSize(val)
+		if perr != nil {
+			return perr
 		}
+		o.setValue(v)
+
+	case StrSliceType, IntSliceType, FloatSliceType:
+		v, perr := parseSlice(o.Type, splitList(val))
+		if perr != nil {
+			return perr
+		}
+		o.setSlice(v, scope)
+
+	case StrMapType:
+		v, perr := parseStrMap(val)
+		if perr != nil {
+			return perr
+		}
+		o.mergeStrMap(v)
+
+	case CustomType:
+		return setCustomText(o.Value(), val)
 
 	case BoolType:
 		switch val {
 		case "1", "t", "T", "true", "TRUE", "True":
-			o.Value = true
+			o.setValue(true)
 		case "0", "f", "F", "false", "FALSE", "False":
-			o.Value = false
+			o.setValue(false)
 		default:
 			err = fmt.Errorf("Invalid boolean value: %s", val)
 		}
@@ -280,6 +659,13 @@ func (o *Option) Validate(v bool) *Option {
 	return o
 }
 
+// Required sets whether the Option has to be set by a config file, an environment variable or a flag for the
+// Config to build.
+func (o *Option) Required(v bool) *Option {
+	o.Options.Required = v
+	return o
+}
+
 // AddFilter adds an OptionFilterFunc to the Option's filter set. It also sets Validate to true.
 func (o *Option) AddFilter(v OptionFilterFunc) *Option {
 	o.Options.Filters = append(o.Options.Filters, v)
@@ -293,7 +679,49 @@ func (o *Option) SortOrder(i int) *Option {
 	return o
 }
 
+// reset sets the Option's value back to its default and forgets where any previous value came from.
+func (o *Option) reset() {
+	if o.Type == CustomType {
+		// custom values are set in place, so each build starts from a new copy of the default. Setting the value
+		// from the default's text would add to values like lists instead.
+		o.update(func(s *optionState) {
+			*s = optionState{value: newCustom(o.custom.defaultValue)}
+		})
+		return
+	}
+
+	o.update(func(s *optionState) {
+		*s = optionState{value: o.DefaultValue}
+	})
+}
+
+// Append sets whether a slice Option's values from each scope are added to the values from earlier scopes, instead of
+// replacing them.
+func (o *Option) Append(v bool) *Option {
+	o.Options.Append = v
+	return o
+}
+
+// EnvVar sets the name of the environment variable that sets the Option, instead of the one derived from its name.
+func (o *Option) EnvVar(name string) *Option {
+	o.Options.EnvVar = name
+	return o
+}
+
+// Sensitive sets whether the Option's value is hidden in debugging output.
+func (o *Option) Sensitive(v bool) *Option {
+	o.Options.Sensitive = v
+	return o
+}
+
 func (o *Option) builtIn() *Option {
 	o.isBuiltIn = true
 	return o
 }
+
+// implicitValue sets the value the Option is set to when its flag is given without one. Other values have to be given
+// with an =, like -name=value.
+func (o *Option) implicitValue(v string) *Option {
+	o.implicit = v
+	return o
+}
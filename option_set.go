@@ -1,7 +1,7 @@
 package config
 
 import (
-	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -12,7 +12,7 @@ type OptionSet map[string]*Option
 func (os OptionSet) Export(includeNonExportable bool, includeNonOverrides bool) map[string]interface{} {
 	tbr := make(map[string]interface{})
 	for _, v := range os {
-		if (v.Options.Exportable || includeNonExportable) && (v.overridden || includeNonOverrides) {
+		if (v.Options.Exportable || includeNonExportable) && (v.load().overridden || includeNonOverrides) {
 			parts := strings.Split(v.Name, ".")
 			var i int
 			var cursor = &tbr
@@ -28,7 +28,7 @@ func (os OptionSet) Export(includeNonExportable bool, includeNonOverrides bool)
 
 				i++
 			}
-			(*cursor)[parts[i]] = v.Value
+			(*cursor)[parts[i]] = v.exportValue()
 		}
 	}
 	return tbr
@@ -36,6 +36,11 @@ func (os OptionSet) Export(includeNonExportable bool, includeNonOverrides bool)
 
 // Add adds an Option to an OptionSet with a key of the Option's name.
 func (os OptionSet) Add(o *Option) {
+	// make sure the copies made while building share the Option's listeners, even if there aren't any yet
+	if o.listeners == nil {
+		o.listeners = &listeners{}
+	}
+
 	os[o.Name] = o
 }
 
@@ -54,54 +59,57 @@ func (os OptionSet) Require(key string) *Option {
 	return result
 }
 
-// Validate checks all Options in an OptionSet and returns an error if any of them don't pass any of their Filters and are Required.
-func (os OptionSet) Validate() error {
-	hasError := false
+// Validate checks all Options in an OptionSet that have Validate set, along with `rules`, and returns an error if any
+// of them don't pass any of their Filters or any of the rules fail. The error is a MultiError holding a
+// ValidationError for each Option, in order of their names, followed by one for each failed Rule.
+func (os OptionSet) Validate(rules ...Rule) error {
+	names := make([]string, 0, len(os))
+	for k := range os {
+		names = append(names, k)
+	}
+	sort.Strings(names)
 
-	invalidOpts := make(optionFilterValidationSet, 0)
+	invalidOpts := []error{}
+
+	for _, k := range names {
+		v := os[k]
+		if !v.Options.Validate {
+			continue
+		}
 
-	for _, v := range os {
 		validOption := true
-		errs := []string{}
+		errs := []error{}
 		for _, f := range v.Options.Filters {
 			res, err := f(v)
 			validOption = validOption && res
 			if err != nil {
-				errs = append(errs, err.Error())
+				errs = append(errs, err)
 			}
 		}
 
 		if !validOption {
-			invalidOpts = append(invalidOpts, optionFilterValidation{
-				name:   v.Name,
-				errors: errs,
+			invalidOpts = append(invalidOpts, ValidationError{
+				Option: v.Name,
+				From:   v.Provenance(),
+				Errors: errs,
 			})
 		}
-		hasError = hasError || !validOption
 	}
 
-	if hasError {
-		return invalidOpts
+	for _, rule := range rules {
+		err := rule(os)
+		if err != nil {
+			invalidOpts = append(invalidOpts, ValidationError{
+				Errors: []error{err},
+			})
+		}
 	}
-	return nil
-}
-
-type optionFilterValidation struct {
-	name   string
-	errors []string
-}
-
-func (e optionFilterValidation) Error() string {
-	return fmt.Sprintf("%s: %s", e.name, strings.Join(e.errors, "; "))
-}
 
-type optionFilterValidationSet []optionFilterValidation
-
-func (e optionFilterValidationSet) Error() string {
-	str := []string{}
-	for _, v := range e {
-		str = append(str, "  "+v.Error())
+	if len(invalidOpts) > 0 {
+		return MultiError{
+			Errors:  invalidOpts,
+			heading: "Some options were empty or invalid",
+		}
 	}
-
-	return fmt.Sprintf("Some options were empty or invalid:\n%s", strings.Join(str, "\n"))
+	return nil
 }
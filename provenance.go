@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Source is the kind of place an Option's value came from.
+type Source string
+
+// These are the places an Option's value can come from.
+const (
+	DefaultSource Source = "default"
+	FileSource    Source = "file"
+	EnvSource     Source = "env"
+	FlagSource    Source = "flag"
+	RuntimeSource Source = "runtime"
+)
+
+// Provenance describes where an Option's value came from. Only the fields that make sense for its Source are set.
+type Provenance struct {
+	Source Source
+
+	// Scope is the scope the value was set in: a SearchFile's scope, or "env", "flag" or "runtime".
+	Scope string
+
+	// Path is the config file the value was read from, and Line and Column are where it was in the file, starting
+	// at 1, if the file's Codec could tell.
+	Path   string
+	Line   int
+	Column int
+
+	// Arg is the index of the flag in the arguments passed to BuildArgs, or -1 if it isn't known.
+	Arg int
+
+	// EnvVar is the name of the environment variable the value was read from.
+	EnvVar string
+
+	// Time is when the value was set.
+	Time time.Time
+}
+
+// Position is a line and column in a config file, starting at 1.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// A Locator is a Codec that can also tell where the values in a file are, so that they can be reported in errors and
+// in each value's Provenance.
+type Locator interface {
+	// Locate returns the position of the value of each key in a config file, keyed by the full name, i.e. the value
+	// at ["addend"]["a"] is found at "addend.a".
+	Locate([]byte) (map[string]Position, error)
+}
+
+// String describes where the value came from, e.g. `app config file ./config.json:3:8`.
+func (p Provenance) String() string {
+	switch p.Source {
+	case FileSource:
+		return fmt.Sprintf("%s config file %s", p.Scope, p.location())
+	case EnvSource:
+		return fmt.Sprintf("environment variable %s", p.EnvVar)
+	case FlagSource:
+		if p.Arg < 0 {
+			return "command-line flag"
+		}
+		return fmt.Sprintf("command-line argument %d", p.Arg+1)
+	case RuntimeSource:
+		return "runtime"
+	}
+
+	return "default value"
+}
+
+// location returns the file and position of a value from a file, like path:line:column.
+func (p Provenance) location() string {
+	switch {
+	case p.Line > 0 && p.Column > 0:
+		return fmt.Sprintf("%s:%d:%d", p.Path, p.Line, p.Column)
+	case p.Line > 0:
+		return fmt.Sprintf("%s:%d", p.Path, p.Line)
+	}
+
+	return p.Path
+}
+
+// sourceOf returns the Source of values set in `scope` without more information, i.e. through AddScope.
+func sourceOf(scope string) Source {
+	switch scope {
+	case "env":
+		return EnvSource
+	case "flag":
+		return FlagSource
+	case "runtime":
+		return RuntimeSource
+	}
+
+	return FileSource
+}
+
+// Provenance returns where the Option's current value came from. If a slice or map Option's value was combined from
+// more than one place, this is the last one.
+func (o Option) Provenance() Provenance {
+	p := o.load().provenance
+	if len(p) == 0 {
+		return Provenance{Source: DefaultSource}
+	}
+
+	return p[len(p)-1]
+}
+
+// addProvenance records that the Option's value came from `p`.
+func (o *Option) addProvenance(p Provenance) {
+	if p.Time.IsZero() {
+		p.Time = time.Now()
+	}
+
+	o.update(func(s *optionState) {
+		s.provenance = append(append(make([]Provenance, 0, len(s.provenance)+1), s.provenance...), p)
+	})
+}
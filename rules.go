@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Rule checks the values of more than one Option at once, like two Options that can't both be set. It's checked
+// after the config files, environment variables and flags have all been applied, and returns an error describing
+// the problem if the values aren't valid.
+type Rule func(OptionSet) error
+
+// AddRule adds Rules that are checked along with the Options' Filters every time the Config is built, reloaded or set.
+func (c *Config) AddRule(rules ...Rule) {
+	c.rules = append(c.rules, rules...)
+}
+
+// AddRule adds Rules to the default Config. See (*Config).AddRule.
+func AddRule(rules ...Rule) {
+	baseConfig.AddRule(rules...)
+}
+
+// MutuallyExclusive returns a Rule that fails if more than one of the named Options is set. An Option counts as set
+// if a config file, an environment variable, a flag or Set gave it a value, even one that's the same as its default,
+// like `-subtract=false`; its default value alone doesn't count, whatever it is.
+func MutuallyExclusive(names ...string) Rule {
+	return func(os OptionSet) error {
+		opts, err := lookupAll(os, names)
+		if err != nil {
+			return err
+		}
+
+		set := []string{}
+		for _, o := range opts {
+			if isSet(o) {
+				set = append(set, describe(o))
+			}
+		}
+
+		if len(set) > 1 {
+			return fmt.Errorf("%s can't be set together", joinNames(set))
+		}
+		return nil
+	}
+}
+
+// RequiredTogether returns a Rule that fails if some of the named Options are set but others aren't: they have to
+// be set together or not at all.
+func RequiredTogether(names ...string) Rule {
+	return func(os OptionSet) error {
+		opts, err := lookupAll(os, names)
+		if err != nil {
+			return err
+		}
+
+		set, unset := []string{}, []string{}
+		for _, o := range opts {
+			if isSet(o) {
+				set = append(set, describe(o))
+			} else {
+				unset = append(unset, "-"+o.Name)
+			}
+		}
+
+		if len(set) > 0 && len(unset) > 0 {
+			return fmt.Errorf("%s must be set along with %s", joinNames(unset), joinNames(set))
+		}
+		return nil
+	}
+}
+
+// RequiredIf returns a Rule that fails if the Option named `other` is set, but the one named `name` isn't.
+func RequiredIf(name string, other string) Rule {
+	return func(os OptionSet) error {
+		opts, err := lookupAll(os, []string{name, other})
+		if err != nil {
+			return err
+		}
+
+		if isSet(opts[1]) && !isSet(opts[0]) {
+			return fmt.Errorf("-%s is required when %s is set", name, describe(opts[1]))
+		}
+		return nil
+	}
+}
+
+// lookupAll returns the Options named in `names`, in order.
+func lookupAll(os OptionSet, names []string) ([]*Option, error) {
+	opts := make([]*Option, len(names))
+	for i, name := range names {
+		o, exists := os.Get(name)
+		if !exists {
+			return nil, fmt.Errorf("rule refers to an unknown option %s", name)
+		}
+		opts[i] = o
+	}
+
+	return opts, nil
+}
+
+// isSet returns true if the Option's value was set by a config file, an environment variable, a flag or Set, the same
+// way Required checks it. A value that's set to its default, or to an empty value, still counts.
+func isSet(o *Option) bool {
+	return len(o.load().provenance) > 0
+}
+
+// describe names an Option in an error message, along with where its value came from.
+func describe(o *Option) string {
+	from := o.Provenance()
+	if from.Source == DefaultSource {
+		return "-" + o.Name
+	}
+	return fmt.Sprintf("-%s (from %s)", o.Name, from)
+}
+
+func joinNames(names []string) string {
+	if len(names) <= 1 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is a number of bytes, like the size of a cache or an upload limit.
+type ByteSize uint64
+
+// These are the units a ByteSize can be written in. KB, MB and so on are powers of 1000, and KiB, MiB and so on are
+// powers of 1024.
+const (
+	Byte ByteSize = 1
+
+	KB = 1000 * Byte
+	MB = 1000 * KB
+	GB = 1000 * MB
+	TB = 1000 * GB
+	PB = 1000 * TB
+	EB = 1000 * PB
+
+	KiB = 1024 * Byte
+	MiB = 1024 * KiB
+	GiB = 1024 * MiB
+	TiB = 1024 * GiB
+	PiB = 1024 * TiB
+	EiB = 1024 * PiB
+)
+
+// sizeUnits are the units a ByteSize is formatted with, largest first. At each power, the binary unit comes first
+// since it's the larger of the two.
+var sizeUnits = []struct {
+	name string
+	size ByteSize
+}{
+	{"EiB", EiB}, {"EB", EB},
+	{"PiB", PiB}, {"PB", PB},
+	{"TiB", TiB}, {"TB", TB},
+	{"GiB", GiB}, {"GB", GB},
+	{"MiB", MiB}, {"MB", MB},
+	{"KiB", KiB}, {"KB", KB},
+}
+
+// sizeSuffixes maps the lowercased unit suffixes parseSize accepts to their sizes. A suffix without a "b" is the same
+// as one with it, so 512k is 512KB and 2Gi is 2GiB.
+var sizeSuffixes = map[string]ByteSize{
+	"": Byte, "b": Byte,
+	"k": KB, "kb": KB, "ki": KiB, "kib": KiB,
+	"m": MB, "mb": MB, "mi": MiB, "mib": MiB,
+	"g": GB, "gb": GB, "gi": GiB, "gib": GiB,
+	"t": TB, "tb": TB, "ti": TiB, "tib": TiB,
+	"p": PB, "pb": PB, "pi": PiB, "pib": PiB,
+	"e": EB, "eb": EB, "ei": EiB, "eib": EiB,
+}
+
+// String returns the size in the largest unit it can be written in with at most two decimal places, like 100MB or
+// 1.5GiB, or as a number of bytes if there isn't one.
+func (s ByteSize) String() string {
+	for _, u := range sizeUnits {
+		if s < u.size {
+			continue
+		}
+
+		str := strconv.FormatFloat(float64(s)/float64(u.size), 'f', -1, 64)
+		if i := strings.IndexByte(str, '.'); i >= 0 && len(str)-i-1 > 2 {
+			continue
+		}
+
+		// make sure the rounding in the division didn't change the size
+		if v, err := parseSize(str + u.name); err == nil && v == s {
+			return str + u.name
+		}
+	}
+
+	return strconv.FormatUint(uint64(s), 10) + "B"
+}
+
+// parseSize parses a size, which is a number followed by an optional unit, like 1024, 100MB, 1.5GiB or 512k. Units
+// aren't case sensitive, and the number can have underscores between digits and a fractional part, as long as the
+// result is a whole number of bytes.
+func parseSize(val string) (ByteSize, error) {
+	s := strings.TrimSpace(val)
+
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.' && r != '_'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	num, suffix := s[:i], strings.TrimSpace(s[i:])
+
+	unit, ok := sizeSuffixes[strings.ToLower(suffix)]
+	if !ok || num == "" {
+		return 0, fmt.Errorf("%q isn't a valid %s (try a number of bytes, or one with a unit like 100MB or 1.5GiB)", val, SizeType)
+	}
+
+	// check the syntax, including where the underscores are, before they're removed
+	_, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, numberError(val, SizeType, err)
+	}
+
+	r, ok := new(big.Rat).SetString(strings.ReplaceAll(num, "_", ""))
+	if !ok {
+		return 0, numberError(val, SizeType, strconv.ErrSyntax)
+	}
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).SetUint64(uint64(unit))))
+
+	if !r.IsInt() {
+		return 0, fmt.Errorf("%q isn't a whole number of bytes", val)
+	}
+	if !r.Num().IsUint64() {
+		return 0, rangeError(val, SizeType)
+	}
+
+	return ByteSize(r.Num().Uint64()), nil
+}
+
+// sizeFromNumber converts a number from a config file, which is a number of bytes, to a ByteSize.
+func sizeFromNumber(v interface{}) (ByteSize, error) {
+	switch v := v.(type) {
+	case int64:
+		if v < 0 {
+			return 0, rangeError(v, SizeType)
+		}
+		return ByteSize(v), nil
+
+	case float64:
+		// float64(math.MaxUint64) rounds up to 2^64, which doesn't fit
+		if math.IsNaN(v) || v < 0 || v >= float64(math.MaxUint64) {
+			return 0, rangeError(v, SizeType)
+		}
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("%v isn't a whole number of bytes", v)
+		}
+		return ByteSize(v), nil
+	}
+
+	return 0, fmt.Errorf("expected a number, got %T", v)
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// isSliceType returns true if `t` is one of the slice Types.
+func isSliceType(t Type) bool {
+	switch t {
+	case StrSliceType, IntSliceType, FloatSliceType:
+		return true
+	}
+	return false
+}
+
+// splitList splits a comma-separated list of values, trimming the whitespace around each one. An empty string is an
+// empty list.
+func splitList(val string) []string {
+	if strings.TrimSpace(val) == "" {
+		return []string{}
+	}
+
+	parts := strings.Split(val, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	return parts
+}
+
+// parseSlice parses each of `parts` into a slice of the element type of `t`.
+func parseSlice(t Type, parts []string) (interface{}, error) {
+	switch t {
+	case StrSliceType:
+		return parts, nil
+
+	case IntSliceType:
+		vals := make([]int64, len(parts))
+		for i, p := range parts {
+			v, err := parseInt(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s element: %w", t, err)
+			}
+			vals[i] = v
+		}
+		return vals, nil
+
+	case FloatSliceType:
+		vals := make([]float64, len(parts))
+		for i, p := range parts {
+			v, err := parseFloat(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s element: %w", t, err)
+			}
+			vals[i] = v
+		}
+		return vals, nil
+	}
+
+	return nil, fmt.Errorf("%s isn't a slice type", t)
+}
+
+// sliceFromJSON converts the elements of an array from a config file into a slice of the element type of `t`.
+func sliceFromJSON(t Type, key string, in []interface{}, from Provenance) (interface{}, error) {
+	switch t {
+	case StrSliceType:
+		vals := make([]string, len(in))
+		for i, v := range in {
+			s, ok := v.(string)
+			if !ok {
+				return nil, TypeError{
+					Key:      fmt.Sprintf("%s[%d]", key, i),
+					Actual:   fmt.Sprintf("%T", v),
+					Value:    v,
+					Expected: StringType,
+					From:     from,
+				}
+			}
+			vals[i] = s
+		}
+		return vals, nil
+
+	case IntSliceType:
+		vals := make([]int64, len(in))
+		for i, v := range in {
+			if n, ok := v.(int64); ok {
+				vals[i] = n
+				continue
+			}
+
+			f, ok := v.(float64)
+			if !ok {
+				return nil, TypeError{
+					Key:      fmt.Sprintf("%s[%d]", key, i),
+					Actual:   fmt.Sprintf("%T", v),
+					Value:    v,
+					Expected: IntType,
+					From:     from,
+				}
+			}
+
+			n, diff, err := floatToInt(f)
+			if err != nil {
+				return nil, ParseError{
+					Key:      fmt.Sprintf("%s[%d]", key, i),
+					Value:    v,
+					Expected: IntType,
+					Err:      err,
+					From:     from,
+				}
+			}
+			if diff > 1e-32 {
+				return nil, TruncationError{
+					Key:        fmt.Sprintf("%s[%d]", key, i),
+					Value:      f,
+					Expected:   IntType,
+					Difference: diff,
+					From:       from,
+				}
+			}
+			vals[i] = n
+		}
+		return vals, nil
+
+	case FloatSliceType:
+		vals := make([]float64, len(in))
+		for i, v := range in {
+			if n, ok := v.(int64); ok {
+				vals[i] = float64(n)
+				continue
+			}
+
+			f, ok := v.(float64)
+			if !ok {
+				return nil, TypeError{
+					Key:      fmt.Sprintf("%s[%d]", key, i),
+					Actual:   fmt.Sprintf("%T", v),
+					Value:    v,
+					Expected: FloatType,
+					From:     from,
+				}
+			}
+			if err := checkFloat(f); err != nil {
+				return nil, ParseError{
+					Key:      fmt.Sprintf("%s[%d]", key, i),
+					Value:    v,
+					Expected: FloatType,
+					Err:      err,
+					From:     from,
+				}
+			}
+			vals[i] = f
+		}
+		return vals, nil
+	}
+
+	return nil, fmt.Errorf("%s isn't a slice type", t)
+}
+
+// appending returns true if values for the Option coming from `scope` should be added to its current value instead of
+// replacing it: either the same scope has already set some of them (like a repeated flag), or the Option appends
+// across scopes. Values from the first scope always replace the default.
+func (o *Option) appending(scope string) bool {
+	scopes := o.load().scopes()
+	if scope == "" || len(scopes) == 0 {
+		return false
+	}
+
+	return scopes[len(scopes)-1] == scope || o.Options.Append
+}
+
+// setSlice sets a slice Option's value to `vals`, or adds `vals` to the current value if appending.
+func (o *Option) setSlice(vals interface{}, scope string) {
+	if !o.appending(scope) {
+		o.setValue(vals)
+		return
+	}
+
+	// always build a new slice, so the default value is never modified
+	switch cur := o.Value().(type) {
+	case []string:
+		o.setValue(append(append([]string{}, cur...), vals.([]string)...))
+	case []int64:
+		o.setValue(append(append([]int64{}, cur...), vals.([]int64)...))
+	case []float64:
+		o.setValue(append(append([]float64{}, cur...), vals.([]float64)...))
+	}
+}
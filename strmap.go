@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseStrMap parses a comma-separated list of key=value pairs.
+func parseStrMap(val string) (map[string]string, error) {
+	vals := map[string]string{}
+	for _, pair := range splitList(val) {
+		i := strings.Index(pair, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid key=value pair %q", pair)
+		}
+
+		vals[pair[:i]] = pair[i+1:]
+	}
+
+	return vals, nil
+}
+
+// strMapFromJSON converts a JSON object into a map of strings, making sure each value is a string. Untyped values,
+// like the ones in an INI section, are used as they're written.
+func strMapFromJSON(key string, in map[string]interface{}, from Provenance) (map[string]string, error) {
+	vals := make(map[string]string, len(in))
+	for k, v := range in {
+		if raw, ok := v.(RawValue); ok {
+			vals[k] = raw.Text
+			continue
+		}
+
+		s, ok := v.(string)
+		if !ok {
+			return nil, TypeError{
+				Key:      key + "." + k,
+				Actual:   fmt.Sprintf("%T", v),
+				Value:    v,
+				Expected: StringType,
+				From:     from,
+			}
+		}
+		vals[k] = s
+	}
+
+	return vals, nil
+}
+
+// strMapPosition returns `from` with the position of the first of the map's untyped values, if the map doesn't have a
+// position of its own, like an INI section.
+func strMapPosition(from Provenance, in map[string]interface{}) Provenance {
+	if from.Line > 0 {
+		return from
+	}
+
+	for _, v := range in {
+		raw, ok := v.(RawValue)
+		if !ok {
+			continue
+		}
+
+		if from.Line == 0 || raw.Line < from.Line || (raw.Line == from.Line && raw.Column < from.Column) {
+			from.Line, from.Column = raw.Line, raw.Column
+		}
+	}
+
+	return from
+}
+
+// mergeStrMap sets each key in `vals` on a map Option, keeping the keys that were already set.
+func (o *Option) mergeStrMap(vals map[string]string) {
+	// always build a new map, so the default value is never modified
+	cur, _ := o.Value().(map[string]string)
+	merged := make(map[string]string, len(cur)+len(vals))
+	for k, v := range cur {
+		merged[k] = v
+	}
+	for k, v := range vals {
+		merged[k] = v
+	}
+
+	o.setValue(merged)
+}
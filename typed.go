@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Opt is an Option whose value is always a T, so its value can be read without a type assertion. It embeds the
+// Option, so it can be set up and added like any other: `config.Add(a.Option)`.
+type Opt[T any] struct {
+	*Option
+}
+
+// These are the Opt types for each of the Option types.
+type (
+	StrOption        = Opt[string]
+	BoolOption       = Opt[bool]
+	IntOption        = Opt[int64]
+	FloatOption      = Opt[float64]
+	DurationOption   = Opt[time.Duration]
+	TimeOption       = Opt[time.Time]
+	StrSliceOption   = Opt[[]string]
+	IntSliceOption   = Opt[[]int64]
+	FloatSliceOption = Opt[[]float64]
+	StrMapOption     = Opt[map[string]string]
+	SizeOption       = Opt[ByteSize]
+)
+
+// Typed returns an Opt for `o`, whose value must be a T. It panics if it isn't, since that's a mistake in the program
+// rather than in its configuration.
+func Typed[T any](o *Option) *Opt[T] {
+	if _, ok := o.Value().(T); !ok {
+		panic(fmt.Sprintf("go-config: Typed(%q) needs an Option with a %s value, got %T", o.Name, typeOf[T](), o.Value()))
+	}
+
+	return &Opt[T]{Option: o}
+}
+
+// Get returns the Option's value.
+func (o *Opt[T]) Get() T {
+	v, _ := o.Value().(T)
+	return v
+}
+
+// NewStr creates a string Option. See Str.
+func NewStr(name string, defaultValue string, description string) *StrOption {
+	return Typed[string](Str(name, defaultValue, description))
+}
+
+// NewBool creates a bool Option. See Bool.
+func NewBool(name string, defaultValue bool, description string) *BoolOption {
+	return Typed[bool](Bool(name, defaultValue, description))
+}
+
+// NewInt creates an int64 Option. See Int.
+func NewInt(name string, defaultValue int64, description string) *IntOption {
+	return Typed[int64](Int(name, defaultValue, description))
+}
+
+// NewFloat creates a float64 Option. See Float.
+func NewFloat(name string, defaultValue float64, description string) *FloatOption {
+	return Typed[float64](Float(name, defaultValue, description))
+}
+
+// NewDuration creates a time.Duration Option. See Duration.
+func NewDuration(name string, defaultValue time.Duration, description string) *DurationOption {
+	return Typed[time.Duration](Duration(name, defaultValue, description))
+}
+
+// NewSize creates a ByteSize Option. See Size.
+func NewSize(name string, defaultValue ByteSize, description string) *SizeOption {
+	return Typed[ByteSize](Size(name, defaultValue, description))
+}
+
+// NewTime creates a time.Time Option. See Time.
+func NewTime(name string, defaultValue time.Time, description string) *TimeOption {
+	return Typed[time.Time](Time(name, defaultValue, description))
+}
+
+// NewStrSlice creates a []string Option. See StrSlice.
+func NewStrSlice(name string, defaultValue []string, description string) *StrSliceOption {
+	return Typed[[]string](StrSlice(name, defaultValue, description))
+}
+
+// NewIntSlice creates a []int64 Option. See IntSlice.
+func NewIntSlice(name string, defaultValue []int64, description string) *IntSliceOption {
+	return Typed[[]int64](IntSlice(name, defaultValue, description))
+}
+
+// NewFloatSlice creates a []float64 Option. See FloatSlice.
+func NewFloatSlice(name string, defaultValue []float64, description string) *FloatSliceOption {
+	return Typed[[]float64](FloatSlice(name, defaultValue, description))
+}
+
+// NewStrMap creates a map[string]string Option. See StrMap.
+func NewStrMap(name string, defaultValue map[string]string, description string) *StrMapOption {
+	return Typed[map[string]string](StrMap(name, defaultValue, description))
+}
+
+// LookupIn returns the value of the Option named `key` in `c` as a T. It returns an error if there's no such Option,
+// or a TypeError if its value isn't a T.
+func LookupIn[T any](c *Config, key string) (T, error) {
+	var zero T
+
+	o, err := c.Get(key)
+	if err != nil {
+		return zero, err
+	}
+
+	v, ok := o.Value().(T)
+	if !ok {
+		return zero, TypeError{
+			Key:      key,
+			Expected: Type(typeOf[T]().String()),
+			Actual:   fmt.Sprintf("%T", o.Value()),
+			Value:    o.Value(),
+		}
+	}
+
+	return v, nil
+}
+
+// Lookup returns the value of the Option named `key` in the default Config as a T. See LookupIn.
+func Lookup[T any](key string) (T, error) {
+	return LookupIn[T](baseConfig, key)
+}
+
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"sort"
+)
+
+// UnknownKeyMode sets what happens when a config file has a key that isn't the name of an Option, like a typo or an
+// Option that's been removed.
+type UnknownKeyMode string
+
+// These are the ways unknown keys can be handled. The zero value uses the Config's mode, which ignores them by default.
+const (
+	IgnoreUnknownKeys UnknownKeyMode = "ignore"
+
+	// WarnUnknownKeys adds an error for each unknown key to the Result's Warnings.
+	WarnUnknownKeys = "warn"
+
+	// StrictUnknownKeys adds an error for each unknown key to the file's parse errors, so the build fails.
+	StrictUnknownKeys = "strict"
+)
+
+// UnknownKeys is how the default Config handles keys in config files that aren't the names of Options. A SearchFile
+// can override it with its own UnknownKeys.
+var UnknownKeys UnknownKeyMode
+
+// unknownKeyMode returns how unknown keys in `f` are handled.
+func (c *Config) unknownKeyMode(f SearchFile) UnknownKeyMode {
+	if f.UnknownKeys != "" {
+		return f.UnknownKeys
+	}
+	if c.UnknownKeys != "" {
+		return c.UnknownKeys
+	}
+	return IgnoreUnknownKeys
+}
+
+// unknownKeyWarnings is returned by jsonConfigMap.Parse when a file's only problems are unknown keys, and they should
+// be reported as warnings.
+type unknownKeyWarnings []error
+
+func (u unknownKeyWarnings) Error() string {
+	return MultiError{Errors: u, heading: "unknown key(s)"}.Error()
+}
+
+// suggest returns the name of the Option in `options` that's closest to `key`, if one is close enough to be a typo.
+// Names are compared the way environment variables are named, so that case and separators don't count.
+func suggest(key string, options OptionSet) string {
+	names := make([]string, 0, len(options))
+	for k, v := range options {
+		if !v.isBuiltIn {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	target := envKey(key)
+	best, bestDist := "", -1
+	for _, name := range names {
+		d := editDistance(target, envKey(name))
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = name, d
+		}
+	}
+
+	if bestDist < 0 || (bestDist > 2 && bestDist > len(target)/3) {
+		return ""
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between `a` and `b`.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
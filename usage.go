@@ -8,31 +8,22 @@ import (
 	"strings"
 )
 
-// Name is the name of the application you're configuring.
+// Name is the name of the application you're configuring with the default Config.
 var Name = os.Args[0]
 
-// Description describes the application you're configuring.
+// Description describes the application you're configuring with the default Config.
 var Description string
 
-// Version is the version of the application you're configuring.
+// Version is the version of the application you're configuring with the default Config.
 var Version string
 
-// Examples contains a list of example commands and what they do.
+// Examples contains a list of example commands and what they do, for the default Config.
 var Examples = []Example{}
 
 // SearchFiles contains a list of files which may or may not exist, and if they do, contain
-// configuration files. The last entry in this list is parsed first, and its values are
+// configuration files for the default Config. The last entry in this list is parsed first, and its values are
 // overwritten by values in files further up the list.
-var SearchFiles = []SearchFile{
-	{
-		Scope: "app",
-		Path:  "./config.json",
-	},
-	{
-		Scope: "user",
-		Path:  "$HOME/." + strings.ToLower(Name) + "/config.json",
-	},
-}
+var SearchFiles = defaultSearchFiles(Name)
 
 // Example describes an example of a proper way to invoke the current Go program.
 type Example struct {
@@ -45,9 +36,17 @@ type Example struct {
 type SearchFile struct {
 	Scope string
 	Path  string
+
+	// Format is the extension of the Codec used to read and write the file, like "yaml". If it's empty, the Codec is
+	// picked by the extension of Path.
+	Format string
+
+	// UnknownKeys sets how keys in the file that aren't the names of Options are handled, instead of the Config's
+	// UnknownKeys.
+	UnknownKeys UnknownKeyMode
 }
 
-// UsageWriter is the io.Writer to use for outputting Usage(). Defaults to stdout.
+// UsageWriter is the io.Writer the default Config uses for outputting Usage(). Defaults to stdout.
 var UsageWriter io.Writer = os.Stdout
 
 type sortedUsageOptionSlice []Option
@@ -65,11 +64,31 @@ func (s sortedUsageOptionSlice) Less(a, b int) bool {
 func (s sortedUsageOptionSlice) Swap(a, b int) { s[a], s[b] = s[b], s[a] }
 func (s sortedUsageOptionSlice) Len() int      { return len(s) }
 
-// Usage prints the help information to UsageWriter (defaults to stdout).
+// defaultSearchFiles returns the SearchFiles used for an application named `name`: ./config.json and
+// $HOME/.<name>/config.json.
+func defaultSearchFiles(name string) []SearchFile {
+	return []SearchFile{
+		{
+			Scope: "app",
+			Path:  "./config.json",
+		},
+		{
+			Scope: "user",
+			Path:  "$HOME/." + strings.ToLower(name) + "/config.json",
+		},
+	}
+}
+
+// Usage prints the help information for the default Config to UsageWriter (defaults to stdout).
 func Usage() {
+	defaultConfig().Usage()
+}
+
+// Usage prints the help information to the Config's UsageWriter.
+func (c *Config) Usage() {
 
 	uprintf := func(strFmt string, args ...interface{}) {
-		fmt.Fprintf(UsageWriter, strFmt, args...)
+		fmt.Fprintf(c.UsageWriter, strFmt, args...)
 	}
 
 	uprintln := func(strFmt string, args ...interface{}) {
@@ -78,7 +97,7 @@ func Usage() {
 
 	mlen := 0
 	opts := []Option{}
-	for _, opt := range baseOptionSet {
+	for _, opt := range c.options {
 		opts = append(opts, *opt)
 		s := fmt.Sprintf("%s", opt.Name)
 		if len(s) > mlen {
@@ -88,21 +107,21 @@ func Usage() {
 
 	sort.Sort(sortedUsageOptionSlice(opts))
 
-	if Version != "" {
-		uprintln(`%s (ver. %s)`, Name, Version)
+	if c.Version != "" {
+		uprintln(`%s (ver. %s)`, c.Name, c.Version)
 	} else {
-		uprintln(`%s`, Name)
+		uprintln(`%s`, c.Name)
 	}
 
-	if Description != "" {
-		uprintln(`%s`, Description)
+	if c.Description != "" {
+		uprintln(`%s`, c.Description)
 	}
 
 	uprintln("")
 
-	if len(Examples) > 0 {
+	if len(c.Examples) > 0 {
 		uprintln("Examples:")
-		for _, v := range Examples {
+		for _, v := range c.Examples {
 			uprintln(" # %s", v.Description)
 			uprintln(" $ %s\n", v.Cmd)
 		}
@@ -113,15 +132,20 @@ func Usage() {
 
 		lastSort := opts[0].Options.SortOrder
 
-		fmtStr := fmt.Sprintf(" -%%-%ds (default: %%s)\n     %%s\n", mlen)
+		fmtStr := fmt.Sprintf(" -%%-%ds %%s\n     %%s\n", mlen)
 		for _, opt := range opts {
 			if opt.Options.SortOrder != lastSort {
 				uprintln("")
 			}
 
+			def := fmt.Sprintf("(default: %s)", opt.defaultValueString("<empty>"))
+			if opt.Options.Required {
+				def = "(required)"
+			}
+
 			uprintln(fmtStr,
 				opt.Name,
-				opt.defaultValueString("<empty>"),
+				def,
 				opt.Description,
 			)
 
@@ -0,0 +1,255 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultWatchInterval is how often Watch checks the config files for changes if the Config's WatchInterval isn't set.
+const DefaultWatchInterval = 2 * time.Second
+
+// fileState is what Watch remembers about a config file to tell when it's changed.
+type fileState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+	sum     [sha256.Size]byte
+}
+
+// statFile returns the current state of the file at `path`. The file is only hashed if its modification time or size
+// differ from `prev`.
+func statFile(path string, prev fileState) fileState {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+
+	state := fileState{
+		exists:  true,
+		modTime: fi.ModTime(),
+		size:    fi.Size(),
+		sum:     prev.sum,
+	}
+
+	if prev.exists && state.modTime.Equal(prev.modTime) && state.size == prev.size {
+		return state
+	}
+
+	by, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fileState{}
+	}
+	state.sum = sha256.Sum256(by)
+
+	return state
+}
+
+// Watch checks the Config's files for changes every WatchInterval until `ctx` is done. When any of them is created,
+// changed or removed, the files, environment and flags from the last build are applied again in the same order as
+// Build, to a copy of the Options. If they're all valid, the new values replace the current ones; otherwise the current
+// values are kept. A config file that exists but can't be read or decoded, like one that's only been partly written,
+// counts as invalid too. Like Set, a reload doesn't change variables passed to Var or structs bound with BindStruct.
+// Errors from reloading, and warnings about unknown keys in the files, are sent on the returned channel, which should
+// be read from until it's closed once `ctx` is done.
+func (c *Config) Watch(ctx context.Context) <-chan error {
+	errs := make(chan error)
+
+	interval := c.WatchInterval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	go func() {
+		defer close(errs)
+
+		states := c.watchedFiles(map[string]fileState{})
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			next := c.watchedFiles(states)
+			if reflect.DeepEqual(next, states) {
+				continue
+			}
+			states = next
+
+			warnings, err := c.reload()
+			if err != nil {
+				warnings = []error{err}
+			}
+
+			for _, w := range warnings {
+				select {
+				case errs <- w:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return errs
+}
+
+// Watch watches the default Config's files for changes. See (*Config).Watch.
+func Watch(ctx context.Context) <-chan error {
+	return defaultConfig().Watch(ctx)
+}
+
+// watchedFiles returns the state of each of the files used by the last build, or the Config's SearchFiles if it
+// hasn't been built.
+func (c *Config) watchedFiles(prev map[string]fileState) map[string]fileState {
+	c.mu.Lock()
+	searchFiles := c.searchFiles
+	if searchFiles == nil {
+		searchFiles = c.SearchFiles
+	}
+	c.mu.Unlock()
+
+	states := make(map[string]fileState, len(searchFiles))
+	for _, f := range searchFiles {
+		path := f.ExpandedPath()
+		states[path] = statFile(path, prev[path])
+	}
+
+	return states
+}
+
+// reload loads a copy of the Config's Options with the arguments from the last build, and if they're valid, replaces
+// the current values with the new ones. It returns the warnings from loading the files if it succeeds.
+func (c *Config) reload() ([]error, error) {
+	defer c.notify()
+
+	c.buildMu.Lock()
+	defer c.buildMu.Unlock()
+
+	c.mu.Lock()
+	args := c.args
+	c.mu.Unlock()
+
+	staged := c.options.clone()
+
+	l, err := c.load(staged, args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build skips files that can't be read, but here that would replace their values with the defaults
+	for _, w := range l.warnings {
+		if _, ok := w.(IOError); ok {
+			return nil, w
+		}
+	}
+
+	err = c.checkRequired(staged, l.searchFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	err = staged.Validate(c.rules...)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.searchFiles = l.searchFiles
+	c.mu.Unlock()
+
+	c.commit(staged)
+	c.report()
+
+	return l.warnings, nil
+}
+
+// clone returns a copy of the OptionSet whose values can be changed without changing the original's.
+func (os OptionSet) clone() OptionSet {
+	out := make(OptionSet, len(os))
+	for k, v := range os {
+		o := *v
+		cur := *v.load()
+
+		if o.Type == CustomType {
+			// custom values are set in place, so the copy needs a value of its own
+			cur.value = newCustom(cur.value)
+		}
+
+		o.state = &atomic.Value{}
+		o.state.Store(&cur)
+
+		out[k] = &o
+	}
+
+	return out
+}
+
+// commit replaces the values of the Config's Options with the values in `staged`. What changed isn't passed to the
+// Options' listeners until report is called, so a build can keep values that fail validation without reporting them.
+// Snapshot waits until they've all been replaced.
+func (c *Config) commit(staged OptionSet) {
+	c.valuesMu.Lock()
+	prev := c.options.commit(staged)
+	c.valuesMu.Unlock()
+
+	// keep the state from before the first change that hasn't been reported, since that's what the listeners last saw
+	if c.unreported == nil {
+		c.unreported = map[string]optionState{}
+	}
+	for k, s := range prev {
+		if _, ok := c.unreported[k]; !ok {
+			c.unreported[k] = s
+		}
+	}
+}
+
+// report queues the changes to the Config's Options since they were last reported, to be passed to their listeners by
+// notify.
+func (c *Config) report() {
+	var changes []Change
+	for k, prev := range c.unreported {
+		o, exists := c.options[k]
+		if !exists || o.isBuiltIn {
+			continue
+		}
+
+		if ch, changed := o.changeOf(&prev, o.load()); changed {
+			changes = append(changes, ch)
+		}
+	}
+	c.unreported = nil
+
+	c.queue(changes)
+}
+
+// commit replaces the values of the Options in the OptionSet with the values of the same Options in `staged`, and
+// returns their previous states. Each Option's value and scopes are replaced together, so readers see either the old
+// state or the new one.
+func (os OptionSet) commit(staged OptionSet) map[string]optionState {
+	prev := make(map[string]optionState, len(staged))
+
+	for k, v := range staged {
+		o, exists := os[k]
+		if !exists {
+			continue
+		}
+
+		prev[k] = *o.load()
+		next := *v.load()
+		o.update(func(s *optionState) {
+			*s = next
+		})
+	}
+
+	return prev
+}